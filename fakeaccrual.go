@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/fakeaccrual"
+	"go.uber.org/zap"
+)
+
+// runFakeAccrual выполняет подкоманду fake-accrual [файл сценария]: запускает имитацию системы
+// расчёта начислений на адресе, заданном флагом -r или ACCRUAL_SYSTEM_ADDRESS.
+func runFakeAccrual(cfg *config.Config, log *zap.Logger, args []string) error {
+
+	if cfg.AccrualSystemAddress == "" {
+		return fmt.Errorf("адрес системы расчёта начислений не заполнен")
+	}
+
+	var script fakeaccrual.Config
+	if len(args) > 0 {
+		var err error
+		script, err = fakeaccrual.LoadConfig(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	fake, err := fakeaccrual.NewServer(script)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: cfg.AccrualSystemAddress, Handler: fake}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Info("имитация системы расчёта начислений запущена", zap.String("address", cfg.AccrualSystemAddress))
+
+	err = server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -1,20 +1,45 @@
 package accrualservice
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/maryakotova/gophermart/internal/breaker"
+	"github.com/maryakotova/gophermart/internal/clock"
 	"github.com/maryakotova/gophermart/internal/config"
 	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/httpclient"
+	"github.com/maryakotova/gophermart/internal/metrics"
 	"github.com/maryakotova/gophermart/internal/models"
 	"go.uber.org/zap"
 )
 
+// defaultTimeout используется, если таймаут запроса не задан в конфигурации.
+const defaultTimeout = 5 * time.Second
+
+// LastRequest - итог последнего запроса к системе расчёта.
+type LastRequest struct {
+	At     time.Time // время запроса, нулевое значение - запросов ещё не было
+	Status int       // HTTP-статус ответа, 0 - ответ не получен
+	Error  string    // ошибка соединения или ответа 5xx, пустая строка - система расчёта доступна
+}
+
 type AccrualService struct {
-	config *config.Config
-	logger *zap.Logger
+	config   *config.Config
+	logger   *zap.Logger
+	throttle *throttle
+	client   *http.Client
+	breaker  *breaker.Breaker
+
+	lastMtx sync.RWMutex
+	last    LastRequest
 }
 
 func NewAccrualSystem(cfg *config.Config, logger *zap.Logger) (*AccrualService, error) {
@@ -22,55 +47,166 @@ func NewAccrualSystem(cfg *config.Config, logger *zap.Logger) (*AccrualService,
 		err := fmt.Errorf("адрес системы расчёта начислений не заполнен")
 		return nil, err
 	}
+
+	timeout := cfg.AccrualTimeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	breakerWait := cfg.AccrualBreakerWait
+	if breakerWait <= 0 {
+		breakerWait = 30 * time.Second
+	}
+
 	return &AccrualService{
-		config: cfg,
-		logger: logger,
+		config:   cfg,
+		logger:   logger,
+		throttle: &throttle{},
+		client:   httpclient.New(timeout, cfg.AccrualWorkers+1),
+		breaker:  breaker.New("accrual", cfg.AccrualBreakerFails, breakerWait, clock.Real{}, logger),
 	}, nil
 }
 
-func (a *AccrualService) GetAccrualFromService(orderNum int64) (response models.AccrualSystemResponce, err error) {
+// GetAccrualFromService запрашивает статус расчёта начислений по заказу.
+// Пока система расчёта ограничивает запросы, возвращает customerrors.ErrAccrualThrottled без обращения к ней,
+// а пока разомкнут предохранитель - customerrors.ErrAccrualUnavailable. Ошибка соединения и ответ 5xx
+// также возвращаются как customerrors.ErrAccrualUnavailable: заказ нужно повторить позже.
+func (a *AccrualService) GetAccrualFromService(ctx context.Context, orderNum int64) (response models.AccrualSystemResponce, err error) {
 
-	url := fmt.Sprintf("http://%s/api/orders/%s", a.config.AccrualSystemAddress, strconv.FormatInt(orderNum, 10))
+	if a.throttle.state().Throttled {
+		return response, customerrors.ErrAccrualThrottled
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	done, err := a.breaker.Allow()
 	if err != nil {
 		return response, err
 	}
 
-	client := &http.Client{}
+	url := fmt.Sprintf("http://%s/api/orders/%s", a.config.AccrualSystemAddress, strconv.FormatInt(orderNum, 10))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		done(true)
+		return response, err
+	}
 
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := a.client.Do(req)
+	metrics.AccrualDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.AccrualRequests.Inc("error")
+		// отмена запроса при остановке сервиса не говорит о недоступности системы расчёта
+		if ctx.Err() != nil {
+			done(true)
+			return response, err
+		}
+		done(false)
+		err = fmt.Errorf("%w: %w", customerrors.ErrAccrualUnavailable, err)
+		a.record(start, 0, err)
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	metrics.AccrualRequests.Inc(strconv.Itoa(resp.StatusCode))
+	done(resp.StatusCode < http.StatusInternalServerError)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		err = fmt.Errorf("%w: статус ответа %d", customerrors.ErrAccrualUnavailable, resp.StatusCode)
+		a.record(start, resp.StatusCode, err)
 		return response, err
 	}
+	a.record(start, resp.StatusCode, nil)
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		decoder := json.NewDecoder(resp.Body)
-		err = decoder.Decode(&response)
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return response, fmt.Errorf("ошибка при чтении ответа: %w", err)
+		}
+		err = json.Unmarshal(body, &response)
 		if err != nil {
 			err = fmt.Errorf("ошибка при десериализации JSON: %w", err)
 			return response, err
 		}
+		response.Raw = body
 
 	case http.StatusNoContent:
 		response = models.AccrualSystemResponce{Order: strconv.FormatInt(orderNum, 10), Status: constants.NotRelevant}
 
 	case http.StatusTooManyRequests:
-		response = models.AccrualSystemResponce{Order: strconv.FormatInt(orderNum, 10), Status: constants.New}
-
-	case http.StatusInternalServerError:
-		err = fmt.Errorf("ошибка при обращении к системе расчёта начислений баллов лояльности")
-		return
+		body, _ := io.ReadAll(resp.Body)
+		state := a.throttle.pause(resp.Header.Get("Retry-After"), string(body), time.Now())
+		a.logger.Warn("система расчёта начислений ограничила количество запросов",
+			zap.Time("until", state.Until),
+			zap.Int("rate_limit", state.RateLimit),
+		)
+		return response, customerrors.ErrAccrualThrottled
 
 	default:
 		err = fmt.Errorf("невозможно обработать ответ от системы расчёта начислений баллов лояльности (неизвестный статус)")
 		return
 	}
 
-	if response.Status != constants.Invalid && response.Status != constants.Processed && response.Status != constants.NotRelevant {
-		//добавить в поток на обработку воркерами
+	return response, err
+}
+
+// LastRequest возвращает итог последнего запроса к системе расчёта. Проверки состояния сервиса
+// используют его вместо собственных запросов, чтобы не расходовать лимит запросов воркера.
+func (a *AccrualService) LastRequest() LastRequest {
+	a.lastMtx.RLock()
+	defer a.lastMtx.RUnlock()
+
+	return a.last
+}
+
+// BreakerState возвращает состояние предохранителя запросов к системе расчёта.
+func (a *AccrualService) BreakerState() breaker.Snapshot {
+	return a.breaker.Snapshot()
+}
+
+// ThrottleState возвращает текущее состояние ограничения запросов к системе расчёта.
+func (a *AccrualService) ThrottleState() ThrottleState {
+	return a.throttle.state()
+}
+
+// WaitThrottle блокируется, пока действует ограничение запросов к системе расчёта, или до отмены контекста.
+func (a *AccrualService) WaitThrottle(ctx context.Context) error {
+	state := a.throttle.state()
+	if !state.Throttled {
+		return nil
 	}
 
-	return response, err
+	return sleep(ctx, time.Until(state.Until))
+}
+
+// WaitAvailable блокируется, пока разомкнут предохранитель, до времени пробного запроса или до отмены контекста.
+func (a *AccrualService) WaitAvailable(ctx context.Context) error {
+	circuit := a.breaker.Snapshot()
+	if circuit.State != breaker.Open {
+		return nil
+	}
+
+	return sleep(ctx, time.Until(circuit.RetryAt))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (a *AccrualService) record(at time.Time, status int, err error) {
+	last := LastRequest{At: at, Status: status}
+	if err != nil {
+		last.Error = err.Error()
+	}
+
+	a.lastMtx.Lock()
+	a.last = last
+	a.lastMtx.Unlock()
 }
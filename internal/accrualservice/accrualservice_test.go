@@ -0,0 +1,205 @@
+package accrualservice_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/accrualservice"
+	"github.com/maryakotova/gophermart/internal/breaker"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/fakeaccrual"
+	"github.com/maryakotova/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+const testOrder = 12345678903
+
+// testService поднимает имитацию системы расчёта со сценарием fakeCfg и клиента к ней.
+func testService(t *testing.T, fakeCfg fakeaccrual.Config) (*accrualservice.AccrualService, *fakeaccrual.Server) {
+	t.Helper()
+
+	srv, fake, err := fakeaccrual.NewTestServer(fakeCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		AccrualSystemAddress: fakeaccrual.Address(srv),
+		AccrualTimeout:       time.Second,
+		AccrualBreakerFails:  3,
+		AccrualBreakerWait:   time.Minute,
+	}
+
+	accrual, err := accrualservice.NewAccrualSystem(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return accrual, fake
+}
+
+func TestProcessedOrder(t *testing.T) {
+
+	accrual, _ := testService(t, fakeaccrual.Config{
+		Orders: map[string]fakeaccrual.Script{
+			"12345678903": {Statuses: []string{constants.Processing, constants.Processed}, Accrual: models.Points(72950)},
+		},
+	})
+
+	response, err := accrual.GetAccrualFromService(context.Background(), testOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != constants.Processing || response.Accrual != 0 {
+		t.Fatalf("первый ответ: %+v, ожидался статус %s без начисления", response, constants.Processing)
+	}
+
+	response, err = accrual.GetAccrualFromService(context.Background(), testOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Order != "12345678903" || response.Status != constants.Processed || response.Accrual != models.Points(72950) {
+		t.Fatalf("второй ответ: %+v, ожидался статус %s с начислением 729.50", response, constants.Processed)
+	}
+	if len(response.Raw) == 0 {
+		t.Fatal("исходный ответ системы расчёта не сохранён")
+	}
+
+	if last := accrual.LastRequest(); last.Status != http.StatusOK || last.Error != "" {
+		t.Fatalf("последний запрос: %+v, ожидался успешный ответ 200", last)
+	}
+}
+
+func TestNotRegisteredOrder(t *testing.T) {
+
+	accrual, _ := testService(t, fakeaccrual.Config{UnknownNoContent: true})
+
+	response, err := accrual.GetAccrualFromService(context.Background(), testOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Order != "12345678903" || response.Status != constants.NotRelevant {
+		t.Fatalf("ответ на 204: %+v, ожидался статус %s", response, constants.NotRelevant)
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+
+	accrual, fake := testService(t, fakeaccrual.Config{
+		TooManyRequestsRate: 1,
+		RetryAfterSeconds:   120,
+		RateLimit:           5,
+	})
+
+	before := time.Now()
+	_, err := accrual.GetAccrualFromService(context.Background(), testOrder)
+	if !errors.Is(err, customerrors.ErrAccrualThrottled) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, customerrors.ErrAccrualThrottled)
+	}
+
+	state := accrual.ThrottleState()
+	if !state.Throttled || state.RateLimit != 5 {
+		t.Fatalf("состояние ограничения: %+v, ожидалось ограничение 5 запросов в минуту", state)
+	}
+	if wait := state.Until.Sub(before); wait < 119*time.Second || wait > 121*time.Second {
+		t.Fatalf("ограничение на %v, ожидалось на 120s по заголовку Retry-After", wait)
+	}
+
+	// пока действует ограничение, запросы к системе расчёта не отправляются
+	_, err = accrual.GetAccrualFromService(context.Background(), testOrder)
+	if !errors.Is(err, customerrors.ErrAccrualThrottled) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, customerrors.ErrAccrualThrottled)
+	}
+	if requests := fake.Requests("12345678903"); requests != 1 {
+		t.Fatalf("система расчёта получила %d запросов, ожидался 1", requests)
+	}
+
+	// ответ 429 не считается отказом системы расчёта
+	if circuit := accrual.BreakerState(); circuit.State != breaker.Closed || circuit.Failures != 0 {
+		t.Fatalf("состояние предохранителя: %+v, ожидалось замкнутое без неудач", circuit)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := accrual.WaitThrottle(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitThrottle() = %v, ожидалось ожидание до отмены контекста", err)
+	}
+}
+
+func TestInternalServerError(t *testing.T) {
+
+	accrual, _ := testService(t, fakeaccrual.Config{ErrorRate: 1})
+
+	// ответ 5xx означает, что заказ нужно повторить позже
+	_, err := accrual.GetAccrualFromService(context.Background(), testOrder)
+	if !errors.Is(err, customerrors.ErrAccrualUnavailable) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, customerrors.ErrAccrualUnavailable)
+	}
+
+	if circuit := accrual.BreakerState(); circuit.State != breaker.Closed || circuit.Failures != 1 {
+		t.Fatalf("состояние предохранителя: %+v, ожидалась одна неудача", circuit)
+	}
+	if last := accrual.LastRequest(); last.Status != http.StatusInternalServerError || last.Error == "" {
+		t.Fatalf("последний запрос: %+v, ожидался неудачный ответ 500", last)
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+
+	accrual, fake := testService(t, fakeaccrual.Config{ErrorRate: 1})
+
+	for i := 0; i < 3; i++ {
+		_, err := accrual.GetAccrualFromService(context.Background(), testOrder)
+		if !errors.Is(err, customerrors.ErrAccrualUnavailable) {
+			t.Fatalf("запрос %d: ошибка %v, ожидалась %v", i+1, err, customerrors.ErrAccrualUnavailable)
+		}
+		if requests := fake.Requests("12345678903"); requests != i+1 {
+			t.Fatalf("запрос %d не дошёл до системы расчёта", i+1)
+		}
+	}
+
+	circuit := accrual.BreakerState()
+	if circuit.State != breaker.Open {
+		t.Fatalf("после трёх неудач предохранитель в состоянии %s, ожидалось %s", circuit.State, breaker.Open)
+	}
+	if !circuit.RetryAt.After(time.Now()) {
+		t.Fatalf("время пробного запроса %v уже прошло", circuit.RetryAt)
+	}
+
+	// разомкнутый предохранитель отклоняет запросы без обращения к системе расчёта
+	_, err := accrual.GetAccrualFromService(context.Background(), testOrder)
+	if !errors.Is(err, customerrors.ErrAccrualUnavailable) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, customerrors.ErrAccrualUnavailable)
+	}
+	if requests := fake.Requests("12345678903"); requests != 3 {
+		t.Fatalf("система расчёта получила %d запросов, ожидалось 3", requests)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := accrual.WaitAvailable(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitAvailable() = %v, ожидалось ожидание до отмены контекста", err)
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+
+	accrual, err := accrualservice.NewAccrualSystem(&config.Config{AccrualSystemAddress: "127.0.0.1:1", AccrualTimeout: time.Second}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = accrual.GetAccrualFromService(context.Background(), testOrder)
+	if !errors.Is(err, customerrors.ErrAccrualUnavailable) {
+		t.Fatalf("ошибка %v, ожидалась %v", err, customerrors.ErrAccrualUnavailable)
+	}
+	if last := accrual.LastRequest(); last.Status != 0 || last.Error == "" {
+		t.Fatalf("последний запрос: %+v, ожидалась ошибка соединения", last)
+	}
+}
@@ -0,0 +1,90 @@
+package accrualservice
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRetryAfter используется, если система расчёта не передала заголовок Retry-After.
+const defaultRetryAfter = time.Minute
+
+var rateLimitRe = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+type ThrottleState struct {
+	Throttled bool      // действует ли ограничение запросов
+	Until     time.Time // время окончания ограничения
+	RateLimit int       // допустимое количество запросов в минуту, 0 - неизвестно
+}
+
+// throttle хранит общее для всего процесса состояние ограничения запросов к системе расчёта.
+type throttle struct {
+	mtx       sync.RWMutex
+	until     time.Time
+	rateLimit int
+}
+
+func (t *throttle) pause(retryAfter string, body string, now time.Time) ThrottleState {
+
+	delay := parseRetryAfter(retryAfter, now)
+	if delay <= 0 {
+		delay = defaultRetryAfter
+	}
+
+	t.mtx.Lock()
+	if until := now.Add(delay); until.After(t.until) {
+		t.until = until
+	}
+	if limit := parseRateLimit(body); limit > 0 {
+		t.rateLimit = limit
+	}
+	t.mtx.Unlock()
+
+	return t.state()
+}
+
+func (t *throttle) state() ThrottleState {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return ThrottleState{
+		Throttled: time.Now().Before(t.until),
+		Until:     t.until,
+		RateLimit: t.rateLimit,
+	}
+}
+
+// parseRetryAfter разбирает значение заголовка Retry-After в секундах или в формате HTTP-даты.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+func parseRateLimit(body string) int {
+	matches := rateLimitRe.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+
+	return limit
+}
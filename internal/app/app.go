@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/storage"
+	"github.com/maryakotova/gophermart/internal/worker"
+	"go.uber.org/zap"
+)
+
+// defaultShutdownTimeout используется, если время на остановку не задано в конфигурации.
+const defaultShutdownTimeout = 15 * time.Second
+
+// App управляет жизненным циклом сервиса: запускает HTTP-сервер и воркеры, а по сигналу
+// SIGINT/SIGTERM останавливает их в обратном порядке и закрывает хранилище.
+type App struct {
+	config  *config.Config
+	logger  *zap.Logger
+	storage storage.Storage
+	server  *http.Server
+	workers *worker.AccrualWorkerPool
+}
+
+func NewApp(cfg *config.Config, logger *zap.Logger, storage storage.Storage, handler http.Handler, workers *worker.AccrualWorkerPool) *App {
+	return &App{
+		config:  cfg,
+		logger:  logger,
+		storage: storage,
+		server: &http.Server{
+			Addr:              cfg.RunAddress,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		workers: workers,
+	}
+}
+
+// Run запускает сервис и блокируется до получения сигнала остановки, отмены ctx или ошибки HTTP-сервера.
+// Порядок остановки: сервер перестаёт принимать соединения и дожидается текущих запросов, затем
+// воркеры завершают обрабатываемые заказы, и только после этого закрывается пул соединений с базой.
+func (a *App) Run(ctx context.Context) error {
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		a.workers.Run(workersCtx)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		a.logger.Info("сервер запущен", zap.String("address", a.config.RunAddress))
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		a.logger.Info("получен сигнал остановки, завершаем работу")
+	case err := <-serverErr:
+		runErr = fmt.Errorf("ошибка HTTP-сервера: %w", err)
+		a.logger.Error(runErr.Error())
+	}
+
+	timeout := a.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		a.logger.Error("не удалось дождаться завершения запросов", zap.Error(err))
+	}
+
+	stopWorkers()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		a.logger.Error("воркеры не завершились за отведённое время")
+	}
+
+	if err := a.storage.Close(); err != nil {
+		a.logger.Error("ошибка при закрытии хранилища", zap.Error(err))
+	}
+
+	a.logger.Info("сервис остановлен")
+
+	return runErr
+}
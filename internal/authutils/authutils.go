@@ -1,49 +1,180 @@
 package authutils
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"go.uber.org/zap"
 )
 
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID int
+	UserID    int
+	SessionID int64 `json:"sid"`
 }
 
-const TOKEN_EXP = time.Hour * 3
-const SECRET_KEY = "SecretKeyForGophermart"
+// SessionStore проверяет, что сессия, в рамках которой выпущен токен, не отозвана.
+type SessionStore interface {
+	TouchSession(ctx context.Context, sessionID int64) (active bool, err error)
+}
 
-func SetAuthCookie(w http.ResponseWriter, userID int) error {
+// режимы передачи токена авторизации
+const (
+	AuthModeCookie = "cookie" // только cookie auth_token
+	AuthModeBearer = "bearer" // только заголовок Authorization: Bearer
+	AuthModeBoth   = "both"   // cookie и заголовок
+)
 
-	expiresAt := time.Now().Add(TOKEN_EXP)
-	tokenString, err := buildJWTString(userID, expiresAt)
-	if err != nil {
-		return err
+// ephemeralKeyID - идентификатор случайного ключа, который создаётся, если ключи не заданы в конфигурации.
+const ephemeralKeyID = "ephemeral"
+
+// TokenManager выпускает и проверяет токены авторизации. Новые токены подписываются
+// активным ключом, а проверяются любым из настроенных ключей по заголовку kid.
+type TokenManager struct {
+	keys       map[string]*signingKey
+	signing    *signingKey
+	ttl        time.Duration
+	refreshTTL time.Duration
+	mode       string
+	sessions   SessionStore
+}
+
+func NewTokenManager(cfg *config.Config, logger *zap.Logger, sessions SessionStore) (*TokenManager, error) {
+
+	authKeys := cfg.AuthKeys
+	signingKeyID := cfg.AuthSigningKeyID
+
+	if len(authKeys) == 0 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		authKeys = []config.AuthKey{{ID: ephemeralKeyID, Algorithm: "HS256", Secret: hex.EncodeToString(secret)}}
+		signingKeyID = ephemeralKeyID
+		logger.Warn("ключи подписи токенов не заданы, используется случайный ключ: после перезапуска токены станут недействительны")
+	}
+
+	tm := &TokenManager{
+		keys:       make(map[string]*signingKey, len(authKeys)),
+		ttl:        cfg.AuthTokenTTL,
+		refreshTTL: cfg.AuthRefreshTTL,
+		mode:       cfg.AuthMode,
+		sessions:   sessions,
+	}
+
+	switch tm.mode {
+	case "":
+		tm.mode = AuthModeBoth
+	case AuthModeCookie, AuthModeBearer, AuthModeBoth:
+	default:
+		return nil, fmt.Errorf("неизвестный режим авторизации: %q", tm.mode)
+	}
+
+	for _, authKey := range authKeys {
+		if _, ok := tm.keys[authKey.ID]; ok {
+			return nil, fmt.Errorf("ключ подписи %q задан несколько раз", authKey.ID)
+		}
+
+		key, err := loadSigningKey(authKey)
+		if err != nil {
+			return nil, err
+		}
+		tm.keys[key.id] = key
+	}
+
+	if signingKeyID == "" {
+		signingKeyID = authKeys[0].ID
+	}
+
+	signing, ok := tm.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("ключ подписи %q не найден среди настроенных ключей", signingKeyID)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("для ключа подписи %q не задан закрытый ключ", signingKeyID)
+	}
+	tm.signing = signing
+
+	if tm.ttl <= 0 {
+		tm.ttl = 15 * time.Minute
+	}
+
+	if tm.refreshTTL <= 0 {
+		tm.refreshTTL = 30 * 24 * time.Hour
 	}
 
+	return tm, nil
+}
+
+// IssueToken выпускает короткоживущий токен доступа в рамках сессии пользователя.
+func (tm *TokenManager) IssueToken(userID int, sessionID int64) (tokenString string, expiresAt time.Time, err error) {
+	expiresAt = time.Now().Add(tm.ttl)
+	tokenString, err = tm.buildJWTString(userID, sessionID, expiresAt)
+	return tokenString, expiresAt, err
+}
+
+// RefreshTTL возвращает время жизни токена обновления.
+func (tm *TokenManager) RefreshTTL() time.Duration {
+	return tm.refreshTTL
+}
+
+// CookieEnabled сообщает, передаётся ли токен в cookie auth_token.
+func (tm *TokenManager) CookieEnabled() bool {
+	return tm.mode == AuthModeCookie || tm.mode == AuthModeBoth
+}
+
+// BearerEnabled сообщает, передаётся ли токен в заголовке Authorization: Bearer.
+func (tm *TokenManager) BearerEnabled() bool {
+	return tm.mode == AuthModeBearer || tm.mode == AuthModeBoth
+}
+
+func SetAuthCookie(w http.ResponseWriter, tokenString string, expiresAt time.Time) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "auth_token",
 		Value:    tokenString,
+		Path:     "/",
 		Expires:  expiresAt,
 		HttpOnly: true,
 	})
+}
 
-	return nil
+// SetRefreshCookie передаёт токен обновления в cookie, доступной только эндпоинту обновления.
+func SetRefreshCookie(w http.ResponseWriter, refreshToken string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshCookieName,
+		Value:    refreshToken,
+		Path:     RefreshCookiePath,
+		Expires:  expiresAt,
+		HttpOnly: true,
+	})
 }
 
-func buildJWTString(userID int, expiresAt time.Time) (string, error) {
+// ClearAuthCookies удаляет cookie с токенами при выходе из сессии.
+func ClearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: "auth_token", Path: "/", MaxAge: -1, HttpOnly: true})
+	http.SetCookie(w, &http.Cookie{Name: RefreshCookieName, Path: RefreshCookiePath, MaxAge: -1, HttpOnly: true})
+}
+
+func (tm *TokenManager) buildJWTString(userID int, sessionID int64, expiresAt time.Time) (string, error) {
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	token := jwt.NewWithClaims(tm.signing.method, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 	})
+	token.Header["kid"] = tm.signing.id
 
-	tokenString, err := token.SignedString([]byte(SECRET_KEY))
+	tokenString, err := token.SignedString(tm.signing.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -51,41 +182,57 @@ func buildJWTString(userID int, expiresAt time.Time) (string, error) {
 	return tokenString, nil
 }
 
-func getUserID(tokenString string) int {
+// ParseToken проверяет подпись и срок действия токена и возвращает пользователя и сессию.
+func (tm *TokenManager) ParseToken(tokenString string) (identity Identity, err error) {
 
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := tm.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("неизвестный ключ подписи: %q", kid)
+		}
+
+		if t.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
 
-		return []byte(SECRET_KEY), nil
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
-		return -1
+		return Identity{UserID: -1}, err
 	}
 
 	if !token.Valid {
-		return -1
+		return Identity{UserID: -1}, fmt.Errorf("токен недействителен")
 	}
 
-	return claims.UserID
+	return Identity{UserID: claims.UserID, SessionID: claims.SessionID}, nil
 }
 
-func ReadAuthCookie(r *http.Request) (userID int, err error) {
-
-	cookie, err := r.Cookie("auth_token")
-	if err != nil {
-		return -1, err
+// ReadToken извлекает токен из заголовка Authorization или cookie auth_token (в зависимости
+// от режима авторизации) и возвращает пользователя и сессию.
+// Если токен не передан, возвращает customerrors.ErrNoAuthToken.
+func (tm *TokenManager) ReadToken(r *http.Request) (identity Identity, err error) {
+
+	if tm.BearerEnabled() {
+		if header := r.Header.Get("Authorization"); header != "" {
+			scheme, tokenString, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+				return Identity{UserID: -1}, fmt.Errorf("некорректный заголовок Authorization")
+			}
+			return tm.ParseToken(strings.TrimSpace(tokenString))
+		}
 	}
 
-	userID = getUserID(cookie.Value)
-
-	if userID == -1 {
-		return userID, err
+	if tm.CookieEnabled() {
+		cookie, err := r.Cookie("auth_token")
+		if err == nil {
+			return tm.ParseToken(cookie.Value)
+		}
 	}
 
-	return userID, nil
+	return Identity{UserID: -1}, customerrors.ErrNoAuthToken
 }
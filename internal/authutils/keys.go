@@ -0,0 +1,155 @@
+package authutils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/maryakotova/gophermart/internal/config"
+)
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil, если ключ используется только для проверки
+	verifyKey interface{}
+}
+
+func loadSigningKey(authKey config.AuthKey) (*signingKey, error) {
+
+	if authKey.ID == "" {
+		return nil, fmt.Errorf("для ключа подписи не задан идентификатор (kid)")
+	}
+
+	method := jwt.GetSigningMethod(authKey.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("ключ %q: неподдерживаемый алгоритм %q", authKey.ID, authKey.Algorithm)
+	}
+
+	key := &signingKey{id: authKey.ID, method: method}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if authKey.Secret == "" {
+			return nil, fmt.Errorf("ключ %q: не задан секрет", authKey.ID)
+		}
+		key.signKey = []byte(authKey.Secret)
+		key.verifyKey = []byte(authKey.Secret)
+
+	case *jwt.SigningMethodRSA:
+		if authKey.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(authKey.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			key.signKey = privateKey
+			key.verifyKey = &privateKey.PublicKey
+		}
+		if authKey.PublicKeyFile != "" {
+			pem, err := os.ReadFile(authKey.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			key.verifyKey = publicKey
+		}
+
+	case *jwt.SigningMethodEd25519:
+		if authKey.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(authKey.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			key.signKey = privateKey
+			key.verifyKey = privateKey.(ed25519.PrivateKey).Public()
+		}
+		if authKey.PublicKeyFile != "" {
+			pem, err := os.ReadFile(authKey.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			publicKey, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("ключ %q: %w", authKey.ID, err)
+			}
+			key.verifyKey = publicKey
+		}
+
+	default:
+		return nil, fmt.Errorf("ключ %q: неподдерживаемый алгоритм %q", authKey.ID, authKey.Algorithm)
+	}
+
+	if key.verifyKey == nil {
+		return nil, fmt.Errorf("ключ %q: не задан ни закрытый, ни открытый ключ", authKey.ID)
+	}
+
+	return key, nil
+}
+
+// JWK - открытый ключ в формате JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKeys возвращает открытые ключи асимметричных алгоритмов, чтобы другие сервисы
+// могли проверять токены. Симметричные ключи не публикуются.
+func (tm *TokenManager) PublicKeys() JWKSet {
+
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range tm.keys {
+		switch publicKey := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.id,
+				Algorithm: key.method.Alg(),
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.id,
+				Algorithm: key.method.Alg(),
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
@@ -0,0 +1,78 @@
+package authutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+)
+
+type contextKey struct{}
+
+// Identity - пользователь, от имени которого выполняется запрос.
+type Identity struct {
+	UserID    int
+	SessionID int64
+}
+
+// Middleware проверяет токен авторизации один раз на запрос и кладёт Identity в контекст.
+// Запросы без токена, с недействительным токеном или в рамках отозванной сессии отклоняются с кодом 401.
+func (tm *TokenManager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		identity, err := tm.ReadToken(r)
+		if err == nil && identity.SessionID == 0 {
+			err = fmt.Errorf("токен выпущен вне сессии")
+		}
+		if err != nil {
+			if tm.BearerEnabled() {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gophermart"`)
+			}
+			switch {
+			case errors.Is(err, customerrors.ErrNoAuthToken):
+				http.Error(w, "требуется авторизация", http.StatusUnauthorized)
+			case errors.Is(err, jwt.ErrTokenExpired):
+				http.Error(w, "срок действия токена истёк", http.StatusUnauthorized)
+			default:
+				http.Error(w, "токен авторизации недействителен", http.StatusUnauthorized)
+			}
+			return
+		}
+
+		if tm.sessions != nil {
+			active, err := tm.sessions.TouchSession(r.Context(), identity.SessionID)
+			if err != nil {
+				http.Error(w, "ошибка при проверке сессии", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				if tm.BearerEnabled() {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="gophermart"`)
+				}
+				http.Error(w, "сессия завершена", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// IdentityFromContext возвращает пользователя, установленного Middleware.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
+
+// UserIDFromContext возвращает идентификатор пользователя из контекста или -1, если запрос не аутентифицирован.
+func UserIDFromContext(ctx context.Context) int {
+	identity, ok := IdentityFromContext(ctx)
+	if !ok {
+		return -1
+	}
+	return identity.UserID
+}
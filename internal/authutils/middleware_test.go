@@ -0,0 +1,117 @@
+package authutils_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/authutils"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"go.uber.org/zap"
+)
+
+func TestMiddlewareSessions(t *testing.T) {
+
+	cfg := &config.Config{}
+	store := memory.NewMemoryStorage(cfg, zap.NewNop())
+	ctx := context.Background()
+
+	tokens, err := authutils.NewTokenManager(cfg, zap.NewNop(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSession := func(userID int, expiresAt time.Time) int64 {
+		sessionID, err := store.CreateSession(ctx, models.Session{UserID: userID, CreatedAt: time.Now(), ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sessionID
+	}
+
+	active := newSession(1, time.Now().Add(time.Hour))
+	revoked := newSession(1, time.Now().Add(time.Hour))
+	expired := newSession(1, time.Now().Add(-time.Second))
+	otherUser := newSession(2, time.Now().Add(time.Hour))
+
+	if err := store.RevokeSession(ctx, 1, revoked); err != nil {
+		t.Fatal(err)
+	}
+	// пользователь не может завершить чужую сессию
+	if err := store.RevokeSession(ctx, 1, otherUser); err != nil {
+		t.Fatal(err)
+	}
+
+	var identity authutils.Identity
+	handler := tokens.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = authutils.IdentityFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name      string
+		userID    int
+		sessionID int64
+		status    int
+	}{
+		{name: "действующая сессия", userID: 1, sessionID: active, status: http.StatusOK},
+		{name: "сессия другого пользователя", userID: 2, sessionID: otherUser, status: http.StatusOK},
+		{name: "отозванная сессия", userID: 1, sessionID: revoked, status: http.StatusUnauthorized},
+		{name: "истёкшая сессия", userID: 1, sessionID: expired, status: http.StatusUnauthorized},
+		{name: "неизвестная сессия", userID: 1, sessionID: 100, status: http.StatusUnauthorized},
+		{name: "токен вне сессии", userID: 1, sessionID: 0, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := tokens.IssueToken(tt.userID, tt.sessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			identity = authutils.Identity{}
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("код %d, ожидался %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusOK && (identity.UserID != tt.userID || identity.SessionID != tt.sessionID) {
+				t.Errorf("пользователь в контексте %+v, ожидался %d в сессии %d", identity, tt.userID, tt.sessionID)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("не передан заголовок WWW-Authenticate")
+			}
+		})
+	}
+
+	// выход на всех устройствах отзывает все сессии пользователя, но не чужие
+	if err := store.RevokeUserSessions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		userID    int
+		sessionID int64
+		status    int
+	}{{1, active, http.StatusUnauthorized}, {2, otherUser, http.StatusOK}} {
+		token, _, err := tokens.IssueToken(tt.userID, tt.sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("сессия %d после выхода на всех устройствах: код %d, ожидался %d", tt.sessionID, rec.Code, tt.status)
+		}
+	}
+}
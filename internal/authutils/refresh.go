@@ -0,0 +1,29 @@
+package authutils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	RefreshCookieName = "refresh_token"
+	RefreshCookiePath = "/api/user/token"
+)
+
+// NewRefreshToken создаёт случайный токен обновления. В хранилище сохраняется только его хеш.
+func NewRefreshToken() (refreshToken string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	refreshToken = base64.RawURLEncoding.EncodeToString(buf)
+	return refreshToken, HashRefreshToken(refreshToken), nil
+}
+
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,154 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/clock"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"go.uber.org/zap"
+)
+
+type State int
+
+const (
+	Closed   State = iota // запросы проходят, неудачи считаются
+	HalfOpen              // пропускается один пробный запрос
+	Open                  // запросы отклоняются без обращения к зависимости
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+type Snapshot struct {
+	State    State
+	Failures int       // неудачи подряд в закрытом состоянии
+	OpenedAt time.Time // время последнего размыкания
+	RetryAt  time.Time // когда в разомкнутом состоянии будет пропущен пробный запрос
+}
+
+// Breaker - предохранитель для обращений к внешней зависимости. После FailureThreshold
+// неудач подряд размыкается и в течение OpenTimeout отклоняет запросы с
+// customerrors.ErrAccrualUnavailable, затем пропускает один пробный запрос: при успехе
+// замыкается, при неудаче снова размыкается.
+type Breaker struct {
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+	clock            clock.Clock
+	logger           *zap.Logger
+
+	mtx        sync.Mutex
+	state      State
+	failures   int
+	openedAt   time.Time
+	probing    bool
+	generation uint64
+}
+
+func New(name string, failureThreshold int, openTimeout time.Duration, clk clock.Clock, logger *zap.Logger) *Breaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if clk == nil {
+		clk = clock.Real{}
+	}
+	return &Breaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		clock:            clk,
+		logger:           logger,
+	}
+}
+
+// Allow сообщает, можно ли выполнить запрос. Если можно, возвращает функцию, которой
+// нужно сообщить результат запроса.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.state == Open && !b.clock.Now().Before(b.openedAt.Add(b.openTimeout)) {
+		b.setState(HalfOpen)
+	}
+
+	switch b.state {
+	case Open:
+		return nil, customerrors.ErrAccrualUnavailable
+	case HalfOpen:
+		if b.probing {
+			return nil, customerrors.ErrAccrualUnavailable
+		}
+		b.probing = true
+	}
+
+	generation := b.generation
+	return func(success bool) { b.record(generation, success) }, nil
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	snapshot := Snapshot{State: b.state, Failures: b.failures, OpenedAt: b.openedAt}
+	if b.state == Open {
+		snapshot.RetryAt = b.openedAt.Add(b.openTimeout)
+	}
+	return snapshot
+}
+
+func (b *Breaker) record(generation uint64, success bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	// результат запроса, начатого до смены состояния, уже ни на что не влияет
+	if generation != b.generation {
+		return
+	}
+
+	switch {
+	case success && b.state == HalfOpen:
+		b.setState(Closed)
+	case success:
+		b.failures = 0
+	case b.state == HalfOpen:
+		b.setState(Open)
+	default:
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.setState(Open)
+		}
+	}
+}
+
+// setState вызывается под блокировкой.
+func (b *Breaker) setState(state State) {
+
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probing = false
+
+	switch state {
+	case Open:
+		b.openedAt = b.clock.Now()
+	case Closed:
+		b.failures = 0
+	}
+
+	b.logger.Warn("состояние предохранителя изменилось",
+		zap.String("breaker", b.name),
+		zap.Stringer("from", from),
+		zap.Stringer("to", state),
+		zap.Int("failures", b.failures),
+	)
+}
@@ -0,0 +1,220 @@
+package breaker_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/breaker"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	testThreshold = 3
+	testTimeout   = time.Minute
+)
+
+// fakeClock - часы, которые идут только по команде теста.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newBreaker() (*breaker.Breaker, *fakeClock) {
+	clk := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	return breaker.New("test", testThreshold, testTimeout, clk, zap.NewNop()), clk
+}
+
+// действия шага сценария
+const (
+	wait     = iota // только сдвинуть часы
+	success         // запрос пропущен и выполнен успешно
+	failure         // запрос пропущен и завершился неудачей
+	rejected        // запрос отклонён без обращения к зависимости
+)
+
+type step struct {
+	advance  time.Duration
+	action   int
+	state    breaker.State
+	failures int // неудачи подряд в замкнутом состоянии
+}
+
+func TestTransitions(t *testing.T) {
+
+	open := []step{
+		{action: failure, state: breaker.Closed, failures: 1},
+		{action: failure, state: breaker.Closed, failures: 2},
+		{action: failure, state: breaker.Open},
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "замкнутый размыкается после порога неудач",
+			steps: append(open[:len(open):len(open)],
+				step{action: rejected, state: breaker.Open},
+			),
+		},
+		{
+			name: "успех сбрасывает счётчик неудач",
+			steps: []step{
+				{action: failure, state: breaker.Closed, failures: 1},
+				{action: failure, state: breaker.Closed, failures: 2},
+				{action: success, state: breaker.Closed},
+				{action: failure, state: breaker.Closed, failures: 1},
+				{action: failure, state: breaker.Closed, failures: 2},
+			},
+		},
+		{
+			name: "успешный пробный запрос замыкает",
+			steps: append(open[:len(open):len(open)],
+				step{advance: testTimeout - time.Second, action: rejected, state: breaker.Open},
+				step{advance: time.Second, action: success, state: breaker.Closed},
+				step{action: failure, state: breaker.Closed, failures: 1},
+			),
+		},
+		{
+			name: "неудачный пробный запрос снова размыкает",
+			steps: append(open[:len(open):len(open)],
+				step{advance: testTimeout, action: failure, state: breaker.Open},
+				step{action: rejected, state: breaker.Open},
+				// время ожидания отсчитывается от повторного размыкания
+				step{advance: testTimeout - time.Second, action: rejected, state: breaker.Open},
+				step{advance: time.Second, action: success, state: breaker.Closed},
+			),
+		},
+		{
+			name: "время без запросов не меняет состояние",
+			steps: append(open[:len(open):len(open)],
+				step{advance: time.Hour, action: wait, state: breaker.Open},
+				step{action: success, state: breaker.Closed},
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clk := newBreaker()
+
+			var openedAt time.Time
+			for i, s := range tt.steps {
+				clk.Advance(s.advance)
+
+				if s.action != wait {
+					done, err := b.Allow()
+					switch {
+					case s.action == rejected && !errors.Is(err, customerrors.ErrAccrualUnavailable):
+						t.Fatalf("шаг %d: Allow() = %v, ожидался отказ", i+1, err)
+					case s.action != rejected && err != nil:
+						t.Fatalf("шаг %d: Allow() = %v, ожидался пропуск запроса", i+1, err)
+					case s.action != rejected:
+						done(s.action == success)
+					}
+				}
+
+				snapshot := b.Snapshot()
+				// счётчик неудач имеет смысл только в замкнутом состоянии
+				if snapshot.State != s.state || (s.state == breaker.Closed && snapshot.Failures != s.failures) {
+					t.Fatalf("шаг %d: состояние %s с %d неудачами, ожидалось %s с %d", i+1,
+						snapshot.State, snapshot.Failures, s.state, s.failures)
+				}
+
+				if snapshot.State != breaker.Open {
+					continue
+				}
+				if !snapshot.OpenedAt.Equal(openedAt) && !snapshot.OpenedAt.Equal(clk.Now()) {
+					t.Fatalf("шаг %d: время размыкания %v", i+1, snapshot.OpenedAt)
+				}
+				openedAt = snapshot.OpenedAt
+				if !snapshot.RetryAt.Equal(openedAt.Add(testTimeout)) {
+					t.Fatalf("шаг %d: пробный запрос в %v, ожидался в %v", i+1, snapshot.RetryAt, openedAt.Add(testTimeout))
+				}
+			}
+		})
+	}
+}
+
+func TestHalfOpenSingleProbe(t *testing.T) {
+
+	b, clk := newBreaker()
+	for i := 0; i < testThreshold; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		done(false)
+	}
+
+	clk.Advance(testTimeout)
+
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatalf("пробный запрос отклонён: %v", err)
+	}
+	if state := b.Snapshot().State; state != breaker.HalfOpen {
+		t.Fatalf("состояние %s, ожидалось %s", state, breaker.HalfOpen)
+	}
+
+	// пока пробный запрос выполняется, остальные отклоняются
+	if _, err := b.Allow(); !errors.Is(err, customerrors.ErrAccrualUnavailable) {
+		t.Fatalf("Allow() = %v, ожидался отказ", err)
+	}
+
+	probe(true)
+	if state := b.Snapshot().State; state != breaker.Closed {
+		t.Fatalf("состояние %s, ожидалось %s", state, breaker.Closed)
+	}
+}
+
+func TestStaleResult(t *testing.T) {
+
+	b, clk := newBreaker()
+
+	// запрос начат, пока предохранитель был замкнут
+	slow, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < testThreshold; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		done(false)
+	}
+
+	// его успех после размыкания не замыкает предохранитель
+	slow(true)
+	if snapshot := b.Snapshot(); snapshot.State != breaker.Open {
+		t.Fatalf("состояние %s, ожидалось %s", snapshot.State, breaker.Open)
+	}
+
+	clk.Advance(testTimeout)
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// и неудача не размыкает его повторно во время пробного запроса
+	slow(false)
+	if snapshot := b.Snapshot(); snapshot.State != breaker.HalfOpen {
+		t.Fatalf("состояние %s, ожидалось %s", snapshot.State, breaker.HalfOpen)
+	}
+
+	probe(true)
+	if snapshot := b.Snapshot(); snapshot.State != breaker.Closed {
+		t.Fatalf("состояние %s, ожидалось %s", snapshot.State, breaker.Closed)
+	}
+}
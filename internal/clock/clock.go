@@ -0,0 +1,16 @@
+package clock
+
+import "time"
+
+// Clock - источник текущего времени. Компоненты, зависящие от времени, получают его
+// через этот интерфейс, чтобы в тестах время можно было подменить.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real возвращает системное время.
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
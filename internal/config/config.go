@@ -1,17 +1,100 @@
 package config
 
+import "time"
+
+// DefaultAuthKeyID - идентификатор ключа, заданного одним секретом через флаг -k или AUTH_SECRET.
+const DefaultAuthKeyID = "default"
+
+// AuthKey описывает ключ подписи токенов. Для HS256/HS384/HS512 задаётся Secret,
+// для RS256 и EdDSA - пути к PEM-файлам. Ключ только с публичной частью используется
+// лишь для проверки токенов, выпущенных до ротации.
+type AuthKey struct {
+	ID             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+// RateLimit задаёт ограничение частоты запросов для именованного маршрута: Requests запросов
+// за Period (например, "1m") с допустимым всплеском Burst.
+type RateLimit struct {
+	Route    string `json:"route"`
+	Requests int    `json:"requests"`
+	Period   string `json:"period"`
+	Burst    int    `json:"burst,omitempty"`
+}
+
 type Config struct {
 	RunAddress           string
 	DatabaseURI          string
 	AccrualSystemAddress string
+	AccrualWorkers       int
+	AccrualPollInterval  time.Duration
+	AccrualTimeout       time.Duration
+	AccrualBreakerFails  int
+	AccrualBreakerWait   time.Duration
+	AuthKeys             []AuthKey
+	AuthSigningKeyID     string
+	AuthTokenTTL         time.Duration
+	AuthRefreshTTL       time.Duration
+	AuthMode             string
+	PasswordHash         string
+	BcryptCost           int
+	Argon2Memory         int
+	Argon2Iterations     int
+	Argon2Parallelism    int
+	PasswordMinLength    int
+	LoginFreeAttempts    int
+	LoginMaxAttempts     int
+	LoginBaseDelay       time.Duration
+	LoginLockout         time.Duration
+	LoginAttemptWindow   time.Duration
+	RateLimitStore       string
+	RateLimits           []RateLimit
+	ShutdownTimeout      time.Duration
+	IdempotencyTTL       time.Duration
 }
 
-func NewConfig() *Config {
-	flags := ParseFlags()
+func NewConfig() (*Config, error) {
+	flags, err := ParseFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	authKeys := flags.AuthKeys
+	if flags.AuthSecret != "" {
+		authKeys = append(authKeys, AuthKey{ID: DefaultAuthKeyID, Algorithm: "HS256", Secret: flags.AuthSecret})
+	}
 
 	return &Config{
 		RunAddress:           flags.RunAddress,
 		DatabaseURI:          flags.DatabaseURI,
 		AccrualSystemAddress: flags.AccrualSystemAddress,
-	}
+		AccrualWorkers:       flags.AccrualWorkers,
+		AccrualPollInterval:  flags.AccrualPollInterval,
+		AccrualTimeout:       flags.AccrualTimeout,
+		AccrualBreakerFails:  flags.AccrualBreakerFails,
+		AccrualBreakerWait:   flags.AccrualBreakerWait,
+		AuthKeys:             authKeys,
+		AuthSigningKeyID:     flags.AuthSigningKeyID,
+		AuthTokenTTL:         flags.AuthTokenTTL,
+		AuthRefreshTTL:       flags.AuthRefreshTTL,
+		AuthMode:             flags.AuthMode,
+		PasswordHash:         flags.PasswordHash,
+		BcryptCost:           flags.BcryptCost,
+		Argon2Memory:         flags.Argon2Memory,
+		Argon2Iterations:     flags.Argon2Iterations,
+		Argon2Parallelism:    flags.Argon2Parallelism,
+		PasswordMinLength:    flags.PasswordMinLength,
+		LoginFreeAttempts:    flags.LoginFreeAttempts,
+		LoginMaxAttempts:     flags.LoginMaxAttempts,
+		LoginBaseDelay:       flags.LoginBaseDelay,
+		LoginLockout:         flags.LoginLockout,
+		LoginAttemptWindow:   flags.LoginAttemptWindow,
+		RateLimitStore:       flags.RateLimitStore,
+		RateLimits:           flags.RateLimits,
+		ShutdownTimeout:      flags.ShutdownTimeout,
+		IdempotencyTTL:       flags.IdempotencyTTL,
+	}, nil
 }
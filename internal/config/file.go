@@ -0,0 +1,219 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileConfig - содержимое JSON-файла конфигурации. Значения из файла имеют наименьший приоритет:
+// их переопределяют явно заданные флаги и переменные окружения.
+type fileConfig struct {
+	RunAddress           string      `json:"run_address"`
+	DatabaseURI          string      `json:"database_uri"`
+	AccrualSystemAddress string      `json:"accrual_system_address"`
+	AccrualWorkers       int         `json:"accrual_workers"`
+	AccrualPollInterval  string      `json:"accrual_poll_interval"`
+	AccrualTimeout       string      `json:"accrual_timeout"`
+	AccrualBreakerFails  int         `json:"accrual_breaker_failures"`
+	AccrualBreakerWait   string      `json:"accrual_breaker_timeout"`
+	AuthSecret           string      `json:"auth_secret"`
+	AuthSigningKeyID     string      `json:"auth_signing_kid"`
+	AuthTokenTTL         string      `json:"auth_token_ttl"`
+	AuthRefreshTTL       string      `json:"auth_refresh_ttl"`
+	AuthKeys             []AuthKey   `json:"auth_keys"`
+	AuthMode             string      `json:"auth_mode"`
+	PasswordHash         string      `json:"password_hash"`
+	BcryptCost           int         `json:"bcrypt_cost"`
+	Argon2Memory         int         `json:"argon2_memory"`
+	Argon2Iterations     int         `json:"argon2_time"`
+	Argon2Parallelism    int         `json:"argon2_threads"`
+	PasswordMinLength    int         `json:"password_min_length"`
+	LoginFreeAttempts    int         `json:"login_free_attempts"`
+	LoginMaxAttempts     int         `json:"login_max_attempts"`
+	LoginBaseDelay       string      `json:"login_base_delay"`
+	LoginLockout         string      `json:"login_lockout"`
+	LoginAttemptWindow   string      `json:"login_window"`
+	RateLimitStore       string      `json:"rate_limit_store"`
+	RateLimits           []RateLimit `json:"rate_limits"`
+	ShutdownTimeout      string      `json:"shutdown_timeout"`
+	IdempotencyTTL       string      `json:"idempotency_ttl"`
+}
+
+func readConfigFile(path string) (*fileConfig, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	var cfg fileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе файла конфигурации: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// apply переносит значения из файла в те поля, которые не заданы флагами явно.
+func (fc *fileConfig) apply(flags *Flags, isSet func(name string) bool) error {
+
+	if fc.RunAddress != "" && !isSet("a") {
+		flags.RunAddress = fc.RunAddress
+	}
+
+	if fc.DatabaseURI != "" && !isSet("d") {
+		flags.DatabaseURI = fc.DatabaseURI
+	}
+
+	if fc.AccrualSystemAddress != "" && !isSet("r") {
+		flags.AccrualSystemAddress = fc.AccrualSystemAddress
+	}
+
+	if fc.AccrualWorkers != 0 && !isSet("w") {
+		flags.AccrualWorkers = fc.AccrualWorkers
+	}
+
+	if fc.AccrualPollInterval != "" && !isSet("p") {
+		interval, err := time.ParseDuration(fc.AccrualPollInterval)
+		if err != nil {
+			return fmt.Errorf("некорректное значение accrual_poll_interval: %w", err)
+		}
+		flags.AccrualPollInterval = interval
+	}
+
+	if fc.AccrualTimeout != "" && !isSet("accrual-timeout") {
+		timeout, err := time.ParseDuration(fc.AccrualTimeout)
+		if err != nil {
+			return fmt.Errorf("некорректное значение accrual_timeout: %w", err)
+		}
+		flags.AccrualTimeout = timeout
+	}
+
+	if fc.AccrualBreakerFails != 0 && !isSet("accrual-breaker-failures") {
+		flags.AccrualBreakerFails = fc.AccrualBreakerFails
+	}
+
+	if fc.AccrualBreakerWait != "" && !isSet("accrual-breaker-timeout") {
+		wait, err := time.ParseDuration(fc.AccrualBreakerWait)
+		if err != nil {
+			return fmt.Errorf("некорректное значение accrual_breaker_timeout: %w", err)
+		}
+		flags.AccrualBreakerWait = wait
+	}
+
+	if fc.AuthSecret != "" && !isSet("k") {
+		flags.AuthSecret = fc.AuthSecret
+	}
+
+	if fc.AuthSigningKeyID != "" && !isSet("kid") {
+		flags.AuthSigningKeyID = fc.AuthSigningKeyID
+	}
+
+	if fc.AuthTokenTTL != "" && !isSet("token-ttl") {
+		ttl, err := time.ParseDuration(fc.AuthTokenTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение auth_token_ttl: %w", err)
+		}
+		flags.AuthTokenTTL = ttl
+	}
+
+	if fc.AuthRefreshTTL != "" && !isSet("refresh-ttl") {
+		ttl, err := time.ParseDuration(fc.AuthRefreshTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение auth_refresh_ttl: %w", err)
+		}
+		flags.AuthRefreshTTL = ttl
+	}
+
+	if fc.AuthMode != "" && !isSet("auth-mode") {
+		flags.AuthMode = fc.AuthMode
+	}
+
+	if fc.PasswordHash != "" && !isSet("password-hash") {
+		flags.PasswordHash = fc.PasswordHash
+	}
+
+	if fc.BcryptCost != 0 && !isSet("bcrypt-cost") {
+		flags.BcryptCost = fc.BcryptCost
+	}
+
+	if fc.Argon2Memory != 0 && !isSet("argon2-memory") {
+		flags.Argon2Memory = fc.Argon2Memory
+	}
+
+	if fc.Argon2Iterations != 0 && !isSet("argon2-time") {
+		flags.Argon2Iterations = fc.Argon2Iterations
+	}
+
+	if fc.Argon2Parallelism != 0 && !isSet("argon2-threads") {
+		flags.Argon2Parallelism = fc.Argon2Parallelism
+	}
+
+	if fc.PasswordMinLength != 0 && !isSet("password-min-length") {
+		flags.PasswordMinLength = fc.PasswordMinLength
+	}
+
+	if fc.LoginFreeAttempts != 0 && !isSet("login-free-attempts") {
+		flags.LoginFreeAttempts = fc.LoginFreeAttempts
+	}
+
+	if fc.LoginMaxAttempts != 0 && !isSet("login-max-attempts") {
+		flags.LoginMaxAttempts = fc.LoginMaxAttempts
+	}
+
+	if fc.LoginBaseDelay != "" && !isSet("login-base-delay") {
+		value, err := time.ParseDuration(fc.LoginBaseDelay)
+		if err != nil {
+			return fmt.Errorf("некорректное значение login_base_delay: %w", err)
+		}
+		flags.LoginBaseDelay = value
+	}
+
+	if fc.LoginLockout != "" && !isSet("login-lockout") {
+		value, err := time.ParseDuration(fc.LoginLockout)
+		if err != nil {
+			return fmt.Errorf("некорректное значение login_lockout: %w", err)
+		}
+		flags.LoginLockout = value
+	}
+
+	if fc.LoginAttemptWindow != "" && !isSet("login-window") {
+		value, err := time.ParseDuration(fc.LoginAttemptWindow)
+		if err != nil {
+			return fmt.Errorf("некорректное значение login_window: %w", err)
+		}
+		flags.LoginAttemptWindow = value
+	}
+
+	if fc.ShutdownTimeout != "" && !isSet("shutdown-timeout") {
+		timeout, err := time.ParseDuration(fc.ShutdownTimeout)
+		if err != nil {
+			return fmt.Errorf("некорректное значение shutdown_timeout: %w", err)
+		}
+		flags.ShutdownTimeout = timeout
+	}
+
+	if fc.IdempotencyTTL != "" && !isSet("idempotency-ttl") {
+		ttl, err := time.ParseDuration(fc.IdempotencyTTL)
+		if err != nil {
+			return fmt.Errorf("некорректное значение idempotency_ttl: %w", err)
+		}
+		flags.IdempotencyTTL = ttl
+	}
+
+	if fc.RateLimitStore != "" && !isSet("rate-limit-store") {
+		flags.RateLimitStore = fc.RateLimitStore
+	}
+
+	if len(fc.RateLimits) > 0 {
+		flags.RateLimits = fc.RateLimits
+	}
+
+	if len(fc.AuthKeys) > 0 {
+		flags.AuthKeys = fc.AuthKeys
+	}
+
+	return nil
+}
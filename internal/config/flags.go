@@ -1,23 +1,102 @@
 package config
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Flags struct {
+	ConfigFile           string
 	RunAddress           string
 	DatabaseURI          string
 	AccrualSystemAddress string
+	AccrualWorkers       int
+	AccrualPollInterval  time.Duration
+	AccrualTimeout       time.Duration
+	AccrualBreakerFails  int
+	AccrualBreakerWait   time.Duration
+	AuthSecret           string
+	AuthSigningKeyID     string
+	AuthTokenTTL         time.Duration
+	AuthRefreshTTL       time.Duration
+	AuthKeys             []AuthKey
+	AuthMode             string
+	PasswordHash         string
+	BcryptCost           int
+	Argon2Memory         int
+	Argon2Iterations     int
+	Argon2Parallelism    int
+	PasswordMinLength    int
+	LoginFreeAttempts    int
+	LoginMaxAttempts     int
+	LoginBaseDelay       time.Duration
+	LoginLockout         time.Duration
+	LoginAttemptWindow   time.Duration
+	RateLimitStore       string
+	RateLimits           []RateLimit
+	ShutdownTimeout      time.Duration
+	IdempotencyTTL       time.Duration
 }
 
-func ParseFlags() *Flags {
+func ParseFlags() (*Flags, error) {
 
 	var flags Flags
 
+	flag.StringVar(&flags.ConfigFile, "c", "", "путь к JSON-файлу конфигурации")
 	flag.StringVar(&flags.RunAddress, "a", "localhost:8080", "адрес и порт запуска сервиса")
-	flag.StringVar(&flags.DatabaseURI, "d", "host=localhost user=gophermart password=test dbname=gophermart sslmode=disable", "адрес подключения к базе данных")
+	flag.StringVar(&flags.DatabaseURI, "d", "", "адрес подключения к базе данных (если не задан, данные хранятся в памяти)")
 	flag.StringVar(&flags.AccrualSystemAddress, "r", "", "адрес системы расчёта начислений")
+	flag.IntVar(&flags.AccrualWorkers, "w", 3, "количество воркеров для опроса системы расчёта начислений")
+	flag.DurationVar(&flags.AccrualPollInterval, "p", time.Second, "интервал загрузки необработанных заказов")
+	flag.DurationVar(&flags.AccrualTimeout, "accrual-timeout", 5*time.Second, "таймаут запроса к системе расчёта начислений")
+	flag.IntVar(&flags.AccrualBreakerFails, "accrual-breaker-failures", 5, "количество неудачных запросов подряд, после которого запросы к системе расчёта приостанавливаются")
+	flag.DurationVar(&flags.AccrualBreakerWait, "accrual-breaker-timeout", 30*time.Second, "время, на которое приостанавливаются запросы к системе расчёта")
+	flag.StringVar(&flags.AuthSecret, "k", "", "секретный ключ для подписи токенов (HS256)")
+	flag.StringVar(&flags.AuthSigningKeyID, "kid", "", "идентификатор ключа, которым подписываются новые токены")
+	flag.DurationVar(&flags.AuthTokenTTL, "token-ttl", 15*time.Minute, "время жизни токена доступа")
+	flag.DurationVar(&flags.AuthRefreshTTL, "refresh-ttl", 30*24*time.Hour, "время жизни токена обновления (сессии)")
+	flag.StringVar(&flags.AuthMode, "auth-mode", "both", "способ передачи токена: cookie, bearer или both")
+	flag.StringVar(&flags.PasswordHash, "password-hash", "bcrypt", "алгоритм хеширования паролей: bcrypt или argon2id")
+	flag.IntVar(&flags.BcryptCost, "bcrypt-cost", 10, "стоимость хеширования bcrypt")
+	flag.IntVar(&flags.Argon2Memory, "argon2-memory", 64*1024, "объём памяти argon2id в КиБ")
+	flag.IntVar(&flags.Argon2Iterations, "argon2-time", 1, "количество проходов argon2id")
+	flag.IntVar(&flags.Argon2Parallelism, "argon2-threads", 4, "количество потоков argon2id")
+	flag.IntVar(&flags.PasswordMinLength, "password-min-length", 8, "минимальная длина пароля при регистрации")
+	flag.IntVar(&flags.LoginFreeAttempts, "login-free-attempts", 3, "количество неудачных попыток входа без задержки")
+	flag.IntVar(&flags.LoginMaxAttempts, "login-max-attempts", 10, "количество неудачных попыток входа до блокировки")
+	flag.DurationVar(&flags.LoginBaseDelay, "login-base-delay", time.Second, "начальная задержка после неудачной попытки входа, удваивается с каждой попыткой")
+	flag.DurationVar(&flags.LoginLockout, "login-lockout", 15*time.Minute, "длительность блокировки входа")
+	flag.DurationVar(&flags.LoginAttemptWindow, "login-window", time.Hour, "период, после которого счётчик неудачных попыток сбрасывается")
+	flag.DurationVar(&flags.ShutdownTimeout, "shutdown-timeout", 15*time.Second, "время на завершение текущих запросов и воркеров при остановке")
+	flag.DurationVar(&flags.IdempotencyTTL, "idempotency-ttl", 24*time.Hour, "время хранения ответов на запросы с заголовком Idempotency-Key")
+	flag.StringVar(&flags.RateLimitStore, "rate-limit-store", "memory", "хранилище счётчиков ограничения частоты запросов: memory, postgres или off")
+
+	flag.Parse()
+
+	if envConfigFile := os.Getenv("CONFIG"); envConfigFile != "" {
+		flags.ConfigFile = envConfigFile
+	}
+
+	if flags.ConfigFile != "" {
+		fileConfig, err := readConfigFile(flags.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+
+		err = fileConfig.apply(&flags, func(name string) bool { return set[name] })
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if envRunAddress := os.Getenv("RUN_ADDRESS"); envRunAddress != "" {
 		flags.RunAddress = envRunAddress
@@ -31,5 +110,157 @@ func ParseFlags() *Flags {
 		flags.AccrualSystemAddress = envAccrualSystemAddress
 	}
 
-	return &flags
+	if envAccrualWorkers := os.Getenv("ACCRUAL_WORKERS"); envAccrualWorkers != "" {
+		if workers, err := strconv.Atoi(envAccrualWorkers); err == nil {
+			flags.AccrualWorkers = workers
+		}
+	}
+
+	if envAccrualPollInterval := os.Getenv("ACCRUAL_POLL_INTERVAL"); envAccrualPollInterval != "" {
+		if interval, err := time.ParseDuration(envAccrualPollInterval); err == nil {
+			flags.AccrualPollInterval = interval
+		}
+	}
+
+	if envAccrualTimeout := os.Getenv("ACCRUAL_TIMEOUT"); envAccrualTimeout != "" {
+		if timeout, err := time.ParseDuration(envAccrualTimeout); err == nil {
+			flags.AccrualTimeout = timeout
+		}
+	}
+
+	if envAccrualBreakerFails := os.Getenv("ACCRUAL_BREAKER_FAILURES"); envAccrualBreakerFails != "" {
+		if failures, err := strconv.Atoi(envAccrualBreakerFails); err == nil {
+			flags.AccrualBreakerFails = failures
+		}
+	}
+
+	if envAccrualBreakerWait := os.Getenv("ACCRUAL_BREAKER_TIMEOUT"); envAccrualBreakerWait != "" {
+		if wait, err := time.ParseDuration(envAccrualBreakerWait); err == nil {
+			flags.AccrualBreakerWait = wait
+		}
+	}
+
+	if envAuthSecret := os.Getenv("AUTH_SECRET"); envAuthSecret != "" {
+		flags.AuthSecret = envAuthSecret
+	}
+
+	if envAuthSigningKeyID := os.Getenv("AUTH_SIGNING_KID"); envAuthSigningKeyID != "" {
+		flags.AuthSigningKeyID = envAuthSigningKeyID
+	}
+
+	if envAuthTokenTTL := os.Getenv("AUTH_TOKEN_TTL"); envAuthTokenTTL != "" {
+		if ttl, err := time.ParseDuration(envAuthTokenTTL); err == nil {
+			flags.AuthTokenTTL = ttl
+		}
+	}
+
+	if envAuthRefreshTTL := os.Getenv("AUTH_REFRESH_TTL"); envAuthRefreshTTL != "" {
+		if ttl, err := time.ParseDuration(envAuthRefreshTTL); err == nil {
+			flags.AuthRefreshTTL = ttl
+		}
+	}
+
+	if envAuthMode := os.Getenv("AUTH_MODE"); envAuthMode != "" {
+		flags.AuthMode = envAuthMode
+	}
+
+	if envPasswordHash := os.Getenv("PASSWORD_HASH"); envPasswordHash != "" {
+		flags.PasswordHash = envPasswordHash
+	}
+
+	if envBcryptCost := os.Getenv("BCRYPT_COST"); envBcryptCost != "" {
+		if value, err := strconv.Atoi(envBcryptCost); err == nil {
+			flags.BcryptCost = value
+		}
+	}
+
+	if envArgon2Memory := os.Getenv("ARGON2_MEMORY"); envArgon2Memory != "" {
+		if value, err := strconv.Atoi(envArgon2Memory); err == nil {
+			flags.Argon2Memory = value
+		}
+	}
+
+	if envArgon2Iterations := os.Getenv("ARGON2_TIME"); envArgon2Iterations != "" {
+		if value, err := strconv.Atoi(envArgon2Iterations); err == nil {
+			flags.Argon2Iterations = value
+		}
+	}
+
+	if envArgon2Parallelism := os.Getenv("ARGON2_THREADS"); envArgon2Parallelism != "" {
+		if value, err := strconv.Atoi(envArgon2Parallelism); err == nil {
+			flags.Argon2Parallelism = value
+		}
+	}
+
+	if envPasswordMinLength := os.Getenv("PASSWORD_MIN_LENGTH"); envPasswordMinLength != "" {
+		if value, err := strconv.Atoi(envPasswordMinLength); err == nil {
+			flags.PasswordMinLength = value
+		}
+	}
+
+	if envLoginFreeAttempts := os.Getenv("LOGIN_FREE_ATTEMPTS"); envLoginFreeAttempts != "" {
+		if value, err := strconv.Atoi(envLoginFreeAttempts); err == nil {
+			flags.LoginFreeAttempts = value
+		}
+	}
+
+	if envLoginMaxAttempts := os.Getenv("LOGIN_MAX_ATTEMPTS"); envLoginMaxAttempts != "" {
+		if value, err := strconv.Atoi(envLoginMaxAttempts); err == nil {
+			flags.LoginMaxAttempts = value
+		}
+	}
+
+	if envLoginBaseDelay := os.Getenv("LOGIN_BASE_DELAY"); envLoginBaseDelay != "" {
+		if value, err := time.ParseDuration(envLoginBaseDelay); err == nil {
+			flags.LoginBaseDelay = value
+		}
+	}
+
+	if envLoginLockout := os.Getenv("LOGIN_LOCKOUT"); envLoginLockout != "" {
+		if value, err := time.ParseDuration(envLoginLockout); err == nil {
+			flags.LoginLockout = value
+		}
+	}
+
+	if envLoginAttemptWindow := os.Getenv("LOGIN_WINDOW"); envLoginAttemptWindow != "" {
+		if value, err := time.ParseDuration(envLoginAttemptWindow); err == nil {
+			flags.LoginAttemptWindow = value
+		}
+	}
+
+	if envShutdownTimeout := os.Getenv("SHUTDOWN_TIMEOUT"); envShutdownTimeout != "" {
+		if timeout, err := time.ParseDuration(envShutdownTimeout); err == nil {
+			flags.ShutdownTimeout = timeout
+		}
+	}
+
+	if envIdempotencyTTL := os.Getenv("IDEMPOTENCY_TTL"); envIdempotencyTTL != "" {
+		if ttl, err := time.ParseDuration(envIdempotencyTTL); err == nil {
+			flags.IdempotencyTTL = ttl
+		}
+	}
+
+	if envRateLimitStore := os.Getenv("RATE_LIMIT_STORE"); envRateLimitStore != "" {
+		flags.RateLimitStore = envRateLimitStore
+	}
+
+	// ограничения частоты запросов передаются JSON-массивом в том же формате, что и в файле конфигурации
+	if envRateLimits := os.Getenv("RATE_LIMITS"); envRateLimits != "" {
+		var limits []RateLimit
+		if err := json.Unmarshal([]byte(envRateLimits), &limits); err != nil {
+			return nil, fmt.Errorf("некорректное значение RATE_LIMITS: %w", err)
+		}
+		flags.RateLimits = limits
+	}
+
+	// набор ключей передаётся JSON-массивом в том же формате, что и в файле конфигурации
+	if envAuthKeys := os.Getenv("AUTH_KEYS"); envAuthKeys != "" {
+		var keys []AuthKey
+		if err := json.Unmarshal([]byte(envAuthKeys), &keys); err != nil {
+			return nil, fmt.Errorf("некорректное значение AUTH_KEYS: %w", err)
+		}
+		flags.AuthKeys = keys
+	}
+
+	return &flags, nil
 }
@@ -6,5 +6,20 @@ const (
 	Processing  = "PROCESSING" // расчёт начисления в процессе
 	Processed   = "PROCESSED"  // расчёт начисления окончен
 	NotRelevant = "NORELEVANT" // заказ не зарегистрирован в системе расчёта
-	New         = "NEW"        // новый заказ, по которому был получеен статус `429`(превышено количество запросов к сервису) от Accrual Service
+	New         = "NEW"        // заказ принят и ожидает обработки системой расчёта начислений
+)
+
+// виды операций в журнале баллов
+const (
+	LedgerAccrual    = "ACCRUAL"    // начисление баллов за заказ
+	LedgerWithdrawal = "WITHDRAWAL" // списание баллов в счёт заказа
+	LedgerAdjustment = "ADJUSTMENT" // ручная корректировка баланса
+)
+
+// результаты загрузки заказа в пакете
+const (
+	BatchAccepted       = "accepted"        // заказ принят в обработку
+	BatchDuplicateOwn   = "duplicate-own"   // заказ уже загружен этим пользователем
+	BatchDuplicateOther = "duplicate-other" // заказ уже загружен другим пользователем
+	BatchInvalid        = "invalid"         // номер заказа некорректен
 )
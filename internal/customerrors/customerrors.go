@@ -3,7 +3,14 @@ package customerrors
 var ErrUsernameTaken = &MyError{Message: "логин уже занят"}
 var ErrOrderLoadedByUser = &MyError{Message: "номер заказа уже был загружен этим пользователем"}
 var ErrOrderLoadedByAnotherUser = &MyError{Message: "номер заказа уже был загружен другим пользователем"}
+var ErrOrderNotFound = &MyError{Message: "заказ не найден"}
 var ErrLowBalance = &MyError{Message: "на счету недостаточно средств"}
+var ErrWithdrawalExists = &MyError{Message: "списание по этому номеру заказа уже выполнено"}
+var ErrNoAuthToken = &MyError{Message: "токен авторизации не передан"}
+var ErrSessionNotFound = &MyError{Message: "сессия не найдена или завершена"}
+var ErrInvalidCredentials = &MyError{Message: "неверная пара логин/пароль"}
+var ErrAccrualThrottled = &MyError{Message: "превышено количество запросов к системе расчёта начислений"}
+var ErrAccrualUnavailable = &MyError{Message: "система расчёта начислений временно недоступна"}
 
 type MyError struct {
 	Message string
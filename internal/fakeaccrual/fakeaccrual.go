@@ -0,0 +1,242 @@
+// Package fakeaccrual - имитация системы расчёта начислений для локальной разработки и тестов.
+// Реализует GET /api/orders/{number} со сценариями смены статусов, правилами начислений
+// и случайными ответами 204/429/500.
+package fakeaccrual
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// Rule задаёт итог расчёта для заказов, номер которых соответствует регулярному выражению Match.
+// Если Status не задан, заказ получает статус PROCESSED с начислением Accrual.
+type Rule struct {
+	Match   string        `json:"match"`
+	Status  string        `json:"status,omitempty"`
+	Accrual models.Points `json:"accrual,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Script - явный сценарий для заказа: статусы, которые заказ проходит при последовательных
+// запросах, и начисление в статусе PROCESSED.
+type Script struct {
+	Statuses []string      `json:"statuses"`
+	Accrual  models.Points `json:"accrual,omitempty"`
+}
+
+type Config struct {
+	// Progression - статусы, которые проходит заказ без явного сценария; последний статус
+	// заменяется итогом из правил. По умолчанию REGISTERED, PROCESSING, PROCESSED.
+	Progression []string `json:"progression,omitempty"`
+	// PollsPerStep - сколько запросов заказ остаётся в каждом промежуточном статусе.
+	PollsPerStep int `json:"polls_per_step,omitempty"`
+	// DefaultAccrual начисляется заказам, которые не подошли ни под одно правило.
+	DefaultAccrual models.Points     `json:"default_accrual,omitempty"`
+	Rules          []Rule            `json:"rules,omitempty"`
+	Orders         map[string]Script `json:"orders,omitempty"`
+	// UnknownNoContent - отвечать 204 на заказы без сценария, как настоящая система на незарегистрированные заказы.
+	UnknownNoContent bool `json:"unknown_no_content,omitempty"`
+
+	// вероятности (от 0 до 1) случайных ответов
+	NoContentRate       float64 `json:"no_content_rate,omitempty"`
+	TooManyRequestsRate float64 `json:"too_many_requests_rate,omitempty"`
+	ErrorRate           float64 `json:"error_rate,omitempty"`
+
+	// RetryAfterSeconds и RateLimit передаются в ответе 429.
+	RetryAfterSeconds int   `json:"retry_after_seconds,omitempty"`
+	RateLimit         int   `json:"rate_limit,omitempty"`
+	Seed              int64 `json:"seed,omitempty"`
+}
+
+// LoadConfig читает сценарий из JSON-файла.
+func LoadConfig(path string) (Config, error) {
+
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("не удалось прочитать сценарий: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("ошибка при разборе сценария: %w", err)
+	}
+
+	return cfg, nil
+}
+
+type order struct {
+	script Script
+	polls  int
+}
+
+// Server - обработчик имитации системы расчёта. Безопасен для конкурентного использования.
+type Server struct {
+	cfg     Config
+	handler http.Handler
+
+	mtx      sync.Mutex
+	rnd      *rand.Rand
+	orders   map[string]*order
+	requests map[string]int
+}
+
+func NewServer(cfg Config) (*Server, error) {
+
+	if len(cfg.Progression) == 0 {
+		cfg.Progression = []string{constants.Registered, constants.Processing, constants.Processed}
+	}
+	if cfg.PollsPerStep < 1 {
+		cfg.PollsPerStep = 1
+	}
+	if cfg.RetryAfterSeconds <= 0 {
+		cfg.RetryAfterSeconds = 60
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = 10
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	for i := range cfg.Rules {
+		re, err := regexp.Compile(cfg.Rules[i].Match)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное правило %q: %w", cfg.Rules[i].Match, err)
+		}
+		cfg.Rules[i].re = re
+	}
+
+	s := &Server{
+		cfg:      cfg,
+		rnd:      rand.New(rand.NewSource(cfg.Seed)),
+		orders:   make(map[string]*order),
+		requests: make(map[string]int),
+	}
+
+	for number, script := range cfg.Orders {
+		s.orders[number] = &order{script: script}
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/orders/{number}", s.getOrder)
+	s.handler = router
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// SetScript задаёт сценарий заказа и сбрасывает его прогресс.
+func (s *Server) SetScript(number string, script Script) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.orders[number] = &order{script: script}
+}
+
+// Requests возвращает количество запросов по заказу, включая ответы с ошибками.
+func (s *Server) Requests(number string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.requests[number]
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+
+	number := chi.URLParam(r, "number")
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.requests[number]++
+
+	switch roll := s.rnd.Float64(); {
+	case roll < s.cfg.TooManyRequestsRate:
+		w.Header().Set("Retry-After", strconv.Itoa(s.cfg.RetryAfterSeconds))
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, "No more than %d requests per minute allowed", s.cfg.RateLimit)
+		return
+	case roll < s.cfg.TooManyRequestsRate+s.cfg.ErrorRate:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	case roll < s.cfg.TooManyRequestsRate+s.cfg.ErrorRate+s.cfg.NoContentRate:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	o, ok := s.orders[number]
+	if !ok {
+		if s.cfg.UnknownNoContent {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		o = &order{script: s.scriptByRules(number)}
+		s.orders[number] = o
+	}
+
+	response := o.next(s.cfg.PollsPerStep)
+	response.Order = number
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// scriptByRules строит сценарий для заказа без явного сценария.
+func (s *Server) scriptByRules(number string) Script {
+
+	final, accrual := constants.Processed, s.cfg.DefaultAccrual
+	for _, rule := range s.cfg.Rules {
+		if rule.re.MatchString(number) {
+			if rule.Status != "" {
+				final = rule.Status
+			}
+			accrual = rule.Accrual
+			break
+		}
+	}
+
+	statuses := append([]string(nil), s.cfg.Progression[:len(s.cfg.Progression)-1]...)
+	statuses = append(statuses, final)
+
+	return Script{Statuses: statuses, Accrual: accrual}
+}
+
+// next возвращает текущий статус заказа и продвигает его по сценарию.
+func (o *order) next(pollsPerStep int) models.AccrualSystemResponce {
+
+	statuses := o.script.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{constants.Processed}
+	}
+
+	step := o.polls / pollsPerStep
+	if step >= len(statuses) {
+		step = len(statuses) - 1
+	}
+	o.polls++
+
+	response := models.AccrualSystemResponce{Status: statuses[step]}
+	if response.Status == constants.Processed {
+		response.Accrual = o.script.Accrual
+	}
+
+	return response
+}
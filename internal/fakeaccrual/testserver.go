@@ -0,0 +1,23 @@
+package fakeaccrual
+
+import (
+	"net/http/httptest"
+	"strings"
+)
+
+// NewTestServer запускает имитацию системы расчёта на случайном порту для тестов. Адрес для
+// config.Config.AccrualSystemAddress возвращает Address. Сервер нужно остановить методом Close.
+func NewTestServer(cfg Config) (*httptest.Server, *Server, error) {
+
+	fake, err := NewServer(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return httptest.NewServer(fake), fake, nil
+}
+
+// Address возвращает адрес тестового сервера без схемы, в формате, который ожидает AccrualService.
+func Address(srv *httptest.Server) string {
+	return strings.TrimPrefix(srv.URL, "http://")
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+func TestLoadOrderBatch(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json; charset=utf-8",
+			body:        `["79927398713", 4561261212345467, "12345678903", "2377225624", "12345678904", "79927398713", true]`,
+		},
+		{
+			name:        "по номеру в строке",
+			contentType: "text/plain",
+			body:        "79927398713\r\n  4561261212345467\n\n12345678903\n2377225624\n12345678904\n79927398713\ntrue\n",
+		},
+	}
+
+	want := []models.BatchOrderResultResponce{
+		{OrderNumber: "79927398713", Result: constants.BatchAccepted, Status: http.StatusAccepted},
+		{OrderNumber: "4561261212345467", Result: constants.BatchAccepted, Status: http.StatusAccepted},
+		{OrderNumber: "12345678903", Result: constants.BatchDuplicateOwn, Status: http.StatusOK},
+		{OrderNumber: "2377225624", Result: constants.BatchDuplicateOther, Status: http.StatusConflict},
+		{OrderNumber: "12345678904", Result: constants.BatchInvalid, Status: http.StatusUnprocessableEntity},
+		// повтор номера внутри пакета
+		{OrderNumber: "79927398713", Result: constants.BatchDuplicateOwn, Status: http.StatusOK},
+		{OrderNumber: "true", Result: constants.BatchInvalid, Status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := testAPI(t)
+			alice := register(t, server, "alice")
+			bob := register(t, server, "bob")
+
+			resp, body := do(t, server, http.MethodPost, "/api/user/orders", alice, "text/plain", "12345678903")
+			expectStatus(t, resp, body, http.StatusAccepted)
+			resp, body = do(t, server, http.MethodPost, "/api/user/orders", bob, "text/plain", "2377225624")
+			expectStatus(t, resp, body, http.StatusAccepted)
+
+			resp, body = do(t, server, http.MethodPost, "/api/user/orders/batch", alice, tt.contentType, tt.body)
+			expectStatus(t, resp, body, http.StatusMultiStatus)
+
+			var batch models.BatchOrdersResponce
+			if err := json.Unmarshal([]byte(body), &batch); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(batch.Results) != len(want) {
+				t.Fatalf("результатов %d, ожидалось %d: %s", len(batch.Results), len(want), body)
+			}
+			for i, item := range batch.Results {
+				if item.OrderNumber != want[i].OrderNumber || item.Result != want[i].Result || item.Status != want[i].Status {
+					t.Errorf("результат %d: %+v, ожидался %+v", i, item, want[i])
+				}
+				if (item.Result == constants.BatchAccepted) != (item.Error == "") {
+					t.Errorf("результат %d: причина отказа %q не соответствует результату %s", i, item.Error, item.Result)
+				}
+			}
+
+			wantSummary := map[string]int{
+				constants.BatchAccepted:       2,
+				constants.BatchDuplicateOwn:   2,
+				constants.BatchDuplicateOther: 1,
+				constants.BatchInvalid:        2,
+			}
+			if fmt.Sprint(batch.Summary) != fmt.Sprint(wantSummary) {
+				t.Errorf("итоги %v, ожидалось %v", batch.Summary, wantSummary)
+			}
+
+			// принятые заказы появляются в списке, заказ другого пользователя - нет
+			resp, body = do(t, server, http.MethodGet, "/api/user/orders", alice, "", "")
+			expectStatus(t, resp, body, http.StatusOK)
+
+			var orders []models.OrderListResponce
+			if err := json.Unmarshal([]byte(body), &orders); err != nil {
+				t.Fatal(err)
+			}
+			if len(orders) != 3 {
+				t.Errorf("список заказов: %+v, ожидалось 3 заказа", orders)
+			}
+		})
+	}
+}
+
+func TestLoadOrderBatchBadRequest(t *testing.T) {
+
+	server, _ := testAPI(t)
+	alice := register(t, server, "alice")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "некорректный JSON", contentType: "application/json", body: `["12345678903"`, status: http.StatusBadRequest},
+		{name: "JSON не массив", contentType: "application/json", body: `{"number":"12345678903"}`, status: http.StatusBadRequest},
+		{name: "пустой массив", contentType: "application/json", body: `[]`, status: http.StatusBadRequest},
+		{name: "пустой текст", contentType: "text/plain", body: "\n  \n", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := do(t, server, http.MethodPost, "/api/user/orders/batch", alice, tt.contentType, tt.body)
+			expectStatus(t, resp, body, tt.status)
+		})
+	}
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/orders/batch", "", "application/json", `["12345678903"]`)
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+}
@@ -5,11 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/maryakotova/gophermart/internal/authutils"
 	"github.com/maryakotova/gophermart/internal/config"
 	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/loginguard"
 	"github.com/maryakotova/gophermart/internal/models"
 	"github.com/maryakotova/gophermart/internal/service"
 	"github.com/maryakotova/gophermart/internal/utils"
@@ -20,13 +27,17 @@ type Handler struct {
 	config  *config.Config
 	logger  *zap.Logger
 	service *service.Service
+	tokens  *authutils.TokenManager
+	guard   *loginguard.Guard
 }
 
-func NewHandler(cfg *config.Config, logger *zap.Logger, service *service.Service) *Handler {
+func NewHandler(cfg *config.Config, logger *zap.Logger, service *service.Service, tokens *authutils.TokenManager, guard *loginguard.Guard) *Handler {
 	return &Handler{
 		config:  cfg,
 		logger:  logger,
 		service: service,
+		tokens:  tokens,
+		guard:   guard,
 	}
 }
 
@@ -48,6 +59,12 @@ func (handler *Handler) Register(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	err = utils.CheckPasswordPolicy(request.Login, request.Password, handler.config.PasswordMinLength)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	userID, err := handler.service.CreateUser(req.Context(), request.Login, request.Password)
 	if err != nil {
 		if errors.Is(err, customerrors.ErrUsernameTaken) {
@@ -64,14 +81,7 @@ func (handler *Handler) Register(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = authutils.SetAuthCookie(res, userID)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	res.Header().Set("Content-Type", "text/plain")
-	res.WriteHeader(http.StatusOK)
+	handler.startSession(res, req, userID)
 
 }
 
@@ -93,35 +103,47 @@ func (handler *Handler) Login(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userID, err := handler.service.CheckLoginData(req.Context(), request.Login, request.Password)
+	ip := clientIP(req)
+
+	retryAfter, err := handler.guard.Check(req.Context(), request.Login, ip)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		writeTooManyAttempts(res, retryAfter)
 		return
 	}
 
-	if userID == -1 {
-		err = fmt.Errorf("неизвестная ошибка при регистрации пользователя")
+	userID, err := handler.service.CheckLoginData(req.Context(), request.Login, request.Password)
+	if errors.Is(err, customerrors.ErrInvalidCredentials) {
+		if _, guardErr := handler.guard.Failure(req.Context(), request.Login, ip); guardErr != nil {
+			handler.logger.Error("не удалось учесть неудачную попытку входа", zap.Error(guardErr))
+		}
+		http.Error(res, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = authutils.SetAuthCookie(res, userID)
-	if err != nil {
+	if err := handler.guard.Success(req.Context(), request.Login); err != nil {
+		handler.logger.Error("не удалось сбросить счётчик попыток входа", zap.Error(err))
+	}
+
+	if userID == -1 {
+		err = fmt.Errorf("неизвестная ошибка при регистрации пользователя")
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	res.Header().Set("Content-Type", "text/plain")
-	res.WriteHeader(http.StatusOK)
+	handler.startSession(res, req, userID)
 
 }
 
 func (handler *Handler) LoadOrder(res http.ResponseWriter, req *http.Request) {
-	userID, err := authutils.ReadAuthCookie(req)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
-		return
-	}
+	userID := authutils.UserIDFromContext(req.Context())
 
 	orderNum, err := io.ReadAll(req.Body)
 	if err != nil {
@@ -153,54 +175,192 @@ func (handler *Handler) LoadOrder(res http.ResponseWriter, req *http.Request) {
 
 }
 
+// ограничения пакетной загрузки заказов
+const (
+	maxBatchOrders    = 1000
+	maxBatchBodyBytes = 1 << 20
+)
+
+// LoadOrderBatch загружает пакет заказов: JSON-массив номеров (Content-Type: application/json)
+// или номера по одному в строке. Отвечает 207 с результатом по каждому номеру.
+func (handler *Handler) LoadOrderBatch(res http.ResponseWriter, req *http.Request) {
+
+	userID := authutils.UserIDFromContext(req.Context())
+
+	body, err := io.ReadAll(http.MaxBytesReader(res, req.Body, maxBatchBodyBytes))
+	if err != nil {
+		http.Error(res, "ошибка при чтении тела запроса", http.StatusBadRequest)
+		return
+	}
+	defer req.Body.Close()
+
+	numbers, err := parseOrderBatch(req.Header.Get("Content-Type"), body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(numbers) == 0 {
+		http.Error(res, "не передано ни одного номера заказа", http.StatusBadRequest)
+		return
+	}
+
+	if len(numbers) > maxBatchOrders {
+		http.Error(res, fmt.Sprintf("в пакете не может быть больше %d заказов", maxBatchOrders), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	response, err := handler.service.LoadOrderBatch(req.Context(), userID, numbers)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusMultiStatus)
+
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(response); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+// parseOrderBatch извлекает номера заказов из JSON-массива строк или чисел либо из текста, по номеру в строке.
+func parseOrderBatch(contentType string, body []byte) (numbers []string, err error) {
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/json" {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, fmt.Errorf("ошибка при десериализации JSON: %w", err)
+		}
+
+		for _, item := range items {
+			var number string
+			if err := json.Unmarshal(item, &number); err != nil {
+				// число передаётся как есть, остальные значения не пройдут проверку номера
+				number = string(item)
+			}
+			numbers = append(numbers, number)
+		}
+		return numbers, nil
+	}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			numbers = append(numbers, line)
+		}
+	}
+	return numbers, nil
+}
+
 func (handler *Handler) GetOrderList(res http.ResponseWriter, req *http.Request) {
 
-	userID, err := authutils.ReadAuthCookie(req)
+	userID := authutils.UserIDFromContext(req.Context())
+
+	query, err := parseListQuery(req, true)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	orders, err := handler.service.GetOrders(req.Context(), userID)
+	orders, next, err := handler.service.GetOrders(req.Context(), userID, query)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setLinkHeader(res, req, next)
+
 	if len(orders) == 0 {
 		res.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
 	enc := json.NewEncoder(res)
 	if err := enc.Encode(orders); err != nil {
-		err = fmt.Errorf("ошибка при заполнении ответа: %w", err)
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+func (handler *Handler) GetOrder(res http.ResponseWriter, req *http.Request) {
+
+	userID := authutils.UserIDFromContext(req.Context())
+
+	orderNumber, err := utils.CheckOrderNumber(chi.URLParam(req, "number"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	order, err := handler.service.GetOrder(req.Context(), userID, orderNumber)
+	if err != nil {
+		if errors.Is(err, customerrors.ErrOrderNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
 
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(order); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
 }
 
-func (handler *Handler) GetBalance(res http.ResponseWriter, req *http.Request) {
+func (handler *Handler) GetOrderTimeline(res http.ResponseWriter, req *http.Request) {
 
-	userID, err := authutils.ReadAuthCookie(req)
+	userID := authutils.UserIDFromContext(req.Context())
+
+	orderNumber, err := utils.CheckOrderNumber(chi.URLParam(req, "number"))
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+		http.Error(res, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
 
-	balance, err := handler.service.GetBalance(req.Context(), userID)
+	timeline, err := handler.service.GetOrderTimeline(req.Context(), userID, orderNumber)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, customerrors.ErrOrderNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
 	enc := json.NewEncoder(res)
-	if err := enc.Encode(balance); err != nil {
-		err = fmt.Errorf("ошибка при заполнении ответа: %w", err)
+	if err := enc.Encode(timeline); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+// GetOrderStats возвращает по дням статистику времени обработки заказов пользователя.
+// Период задаётся параметрами from и to (даты включительно, в формате 2006-01-02), по умолчанию - последние 30 дней.
+func (handler *Handler) GetOrderStats(res http.ResponseWriter, req *http.Request) {
+
+	userID := authutils.UserIDFromContext(req.Context())
+
+	from, to, err := parseDateRange(req, 30)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := handler.service.GetOrderLatencyStats(req.Context(), userID, from, to)
+	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -208,15 +368,36 @@ func (handler *Handler) GetBalance(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
 
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(stats); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
 }
 
-func (handler *Handler) Withdraw(res http.ResponseWriter, req *http.Request) {
-	userID, err := authutils.ReadAuthCookie(req)
+func (handler *Handler) GetBalance(res http.ResponseWriter, req *http.Request) {
+
+	userID := authutils.UserIDFromContext(req.Context())
+
+	balance, err := handler.service.GetBalance(req.Context(), userID)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(balance); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+func (handler *Handler) Withdraw(res http.ResponseWriter, req *http.Request) {
+	userID := authutils.UserIDFromContext(req.Context())
+
 	var request models.WithdrawRequest
 	dec := json.NewDecoder(req.Body)
 	if err := dec.Decode(&request); err != nil {
@@ -230,10 +411,17 @@ func (handler *Handler) Withdraw(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if request.Sum <= 0 {
+		http.Error(res, "сумма списания должна быть положительной", http.StatusUnprocessableEntity)
+		return
+	}
+
 	err = handler.service.WithdrawalRequest(req.Context(), userID, orderNumber, request.Sum)
 	if err != nil {
 		if errors.Is(err, customerrors.ErrLowBalance) {
 			http.Error(res, err.Error(), http.StatusPaymentRequired)
+		} else if errors.Is(err, customerrors.ErrWithdrawalExists) {
+			http.Error(res, err.Error(), http.StatusConflict)
 		} else {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 		}
@@ -246,31 +434,202 @@ func (handler *Handler) Withdraw(res http.ResponseWriter, req *http.Request) {
 
 func (handler *Handler) GetWithdraws(res http.ResponseWriter, req *http.Request) {
 
-	userID, err := authutils.ReadAuthCookie(req)
+	userID := authutils.UserIDFromContext(req.Context())
+
+	query, err := parseListQuery(req, false)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	withdraws, err := handler.service.GetWithdraws(req.Context(), userID)
+	withdraws, next, err := handler.service.GetWithdraws(req.Context(), userID, query)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setLinkHeader(res, req, next)
+
 	if len(withdraws) == 0 {
 		res.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
 	enc := json.NewEncoder(res)
 	if err := enc.Encode(withdraws); err != nil {
-		err = fmt.Errorf("ошибка при заполнении ответа: %w", err)
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+func (handler *Handler) GetJWKS(res http.ResponseWriter, req *http.Request) {
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(handler.tokens.PublicKeys()); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+func (handler *Handler) RefreshToken(res http.ResponseWriter, req *http.Request) {
+
+	var request models.RefreshRequest
+
+	if req.ContentLength != 0 {
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("ошибка при десериализации JSON: %w", err)
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if request.RefreshToken == "" {
+		if cookie, err := req.Cookie(authutils.RefreshCookieName); err == nil {
+			request.RefreshToken = cookie.Value
+		}
+	}
+
+	if request.RefreshToken == "" {
+		http.Error(res, "токен обновления не передан", http.StatusBadRequest)
+		return
+	}
+
+	session, refreshToken, err := handler.service.RefreshSession(req.Context(), request.RefreshToken, handler.tokens.RefreshTTL())
+	if err != nil {
+		if errors.Is(err, customerrors.ErrSessionNotFound) {
+			authutils.ClearAuthCookies(res)
+			http.Error(res, err.Error(), http.StatusUnauthorized)
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	handler.writeAuthToken(res, session.UserID, session.ID, refreshToken, session.ExpiresAt)
+
+}
+
+func (handler *Handler) Logout(res http.ResponseWriter, req *http.Request) {
+
+	identity, _ := authutils.IdentityFromContext(req.Context())
+
+	err := handler.service.Logout(req.Context(), identity.UserID, identity.SessionID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authutils.ClearAuthCookies(res)
+	res.WriteHeader(http.StatusNoContent)
+
+}
+
+func (handler *Handler) LogoutAll(res http.ResponseWriter, req *http.Request) {
+
+	userID := authutils.UserIDFromContext(req.Context())
+
+	err := handler.service.LogoutEverywhere(req.Context(), userID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authutils.ClearAuthCookies(res)
+	res.WriteHeader(http.StatusNoContent)
+
+}
+
+func (handler *Handler) GetSessions(res http.ResponseWriter, req *http.Request) {
+
+	identity, _ := authutils.IdentityFromContext(req.Context())
+
+	sessions, err := handler.service.GetSessions(req.Context(), identity.UserID, identity.SessionID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(sessions); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+// startSession открывает новую сессию после успешного входа или регистрации и передаёт клиенту токены.
+func (handler *Handler) startSession(res http.ResponseWriter, req *http.Request, userID int) {
+
+	refreshTTL := handler.tokens.RefreshTTL()
+	sessionID, refreshToken, err := handler.service.StartSession(req.Context(), userID, req.UserAgent(), clientIP(req), refreshTTL)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	handler.writeAuthToken(res, userID, sessionID, refreshToken, time.Now().Add(refreshTTL))
+
+}
+
+// writeAuthToken выпускает токен доступа и передаёт его вместе с токеном обновления клиенту
+// в cookie, заголовке Authorization и теле ответа в зависимости от режима авторизации.
+func (handler *Handler) writeAuthToken(res http.ResponseWriter, userID int, sessionID int64, refreshToken string, refreshExpiresAt time.Time) {
+
+	token, expiresAt, err := handler.tokens.IssueToken(userID, sessionID)
+	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if handler.tokens.CookieEnabled() {
+		authutils.SetAuthCookie(res, token, expiresAt)
+		authutils.SetRefreshCookie(res, refreshToken, refreshExpiresAt)
+	}
+
+	if !handler.tokens.BearerEnabled() {
+		res.Header().Set("Content-Type", "text/plain")
+		res.WriteHeader(http.StatusOK)
+		return
+	}
+
+	res.Header().Set("Authorization", "Bearer "+token)
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
 
+	enc := json.NewEncoder(res)
+	err = enc.Encode(models.AuthResponce{
+		Token:            token,
+		TokenType:        "Bearer",
+		ExpiresAt:        expiresAt.Format(time.RFC3339),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+
+}
+
+// writeTooManyAttempts отклоняет попытку входа, пока действует задержка или блокировка.
+func writeTooManyAttempts(res http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	res.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(res, "слишком много неудачных попыток входа, повторите позже", http.StatusTooManyRequests)
+}
+
+func clientIP(req *http.Request) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
 }
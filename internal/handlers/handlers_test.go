@@ -0,0 +1,309 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/maryakotova/gophermart/internal/authutils"
+	"github.com/maryakotova/gophermart/internal/clock"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/handlers"
+	"github.com/maryakotova/gophermart/internal/loginguard"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/service"
+	"github.com/maryakotova/gophermart/internal/storage"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"github.com/maryakotova/gophermart/internal/utils"
+	"go.uber.org/zap"
+)
+
+// testAPI поднимает HTTP API на хранилище в памяти с теми же маршрутами, что и в main.
+func testAPI(t *testing.T) (*httptest.Server, *memory.MemoryStorage) {
+
+	cfg := &config.Config{BcryptCost: 4, PasswordMinLength: 8}
+	log := zap.NewNop()
+
+	db := memory.NewMemoryStorage(cfg, log)
+	var store storage.Storage = db
+
+	passwords, err := utils.NewPasswords(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := authutils.NewTokenManager(cfg, log, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := handlers.NewHandler(cfg, log, service.NewService(&store, log, passwords), tokens,
+		loginguard.NewGuard(cfg, log, store, clock.Real{}))
+
+	router := chi.NewRouter()
+	router.Post("/api/user/register", handler.Register)
+	router.Post("/api/user/login", handler.Login)
+	router.Post("/api/user/token/refresh", handler.RefreshToken)
+	router.Group(func(r chi.Router) {
+		r.Use(tokens.Middleware)
+		r.Post("/api/user/orders", handler.LoadOrder)
+		r.Post("/api/user/orders/batch", handler.LoadOrderBatch)
+		r.Get("/api/user/orders", handler.GetOrderList)
+		r.Get("/api/user/balance", handler.GetBalance)
+		r.Post("/api/user/balance/withdraw", handler.Withdraw)
+		r.Get("/api/user/withdrawals", handler.GetWithdraws)
+		r.Post("/api/user/logout", handler.Logout)
+		r.Post("/api/user/logout/all", handler.LogoutAll)
+		r.Get("/api/user/sessions", handler.GetSessions)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server, db
+}
+
+func do(t *testing.T, server *httptest.Server, method string, path string, token string, contentType string, body string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, string(data)
+}
+
+func expectStatus(t *testing.T, resp *http.Response, body string, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		t.Fatalf("%s %s: код %d, ожидался %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+	}
+}
+
+func register(t *testing.T, server *httptest.Server, login string) string {
+	t.Helper()
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/register", "", "application/json",
+		`{"login":"`+login+`","password":"secret123"}`)
+	expectStatus(t, resp, body, http.StatusOK)
+
+	token := resp.Header.Get("Authorization")
+	if token == "" {
+		t.Fatal("токен не передан в заголовке Authorization")
+	}
+	return token
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+
+	server, _ := testAPI(t)
+	register(t, server, "alice")
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/register", "", "application/json", `{"login":"alice","password":"secret123"}`)
+	expectStatus(t, resp, body, http.StatusConflict)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/login", "", "application/json", `{"login":"alice","password":"wrong1234"}`)
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/login", "", "application/json", `{"login":"alice","password":"secret123"}`)
+	expectStatus(t, resp, body, http.StatusOK)
+	token := resp.Header.Get("Authorization")
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/balance", token, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/balance", "", "", "")
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+}
+
+func TestRegisterPasswordPolicy(t *testing.T) {
+
+	server, _ := testAPI(t)
+
+	for _, password := range []string{"secret1", "alice123", "secretsecret"} {
+		resp, body := do(t, server, http.MethodPost, "/api/user/register", "", "application/json",
+			`{"login":"alice123","password":"`+password+`"}`)
+		expectStatus(t, resp, body, http.StatusBadRequest)
+	}
+
+	// отклонённая регистрация не занимает логин
+	register(t, server, "alice123")
+}
+
+func TestRehashOnLogin(t *testing.T) {
+
+	server, db := testAPI(t)
+	register(t, server, "alice")
+
+	ctx := context.Background()
+	userID, err := db.GetUserID(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// хеши, полученные до смены алгоритма и стоимости в конфигурации
+	previous := []utils.PasswordHasher{
+		&utils.BcryptHasher{Cost: 5},
+		&utils.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1},
+	}
+
+	for _, hasher := range previous {
+		encoded, err := hasher.Hash("secret123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.UpdatePasswordHash(ctx, userID, encoded); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, body := do(t, server, http.MethodPost, "/api/user/login", "", "application/json", `{"login":"alice","password":"secret123"}`)
+		expectStatus(t, resp, body, http.StatusOK)
+
+		_, rehashed, err := db.GetUserAuthData(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current := (&utils.BcryptHasher{Cost: 4}); !current.Supports(rehashed) || current.NeedsRehash(rehashed) {
+			t.Errorf("хеш %q не пересчитан по текущей конфигурации", rehashed)
+		}
+
+		resp, body = do(t, server, http.MethodPost, "/api/user/login", "", "application/json", `{"login":"alice","password":"secret123"}`)
+		expectStatus(t, resp, body, http.StatusOK)
+	}
+}
+
+func TestLoadOrder(t *testing.T) {
+
+	server, _ := testAPI(t)
+	alice := register(t, server, "alice")
+	bob := register(t, server, "bob")
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/orders", alice, "text/plain", "12345678903")
+	expectStatus(t, resp, body, http.StatusAccepted)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/orders", alice, "text/plain", "12345678903")
+	expectStatus(t, resp, body, http.StatusOK)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/orders", bob, "text/plain", "12345678903")
+	expectStatus(t, resp, body, http.StatusConflict)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/orders", alice, "text/plain", "12345678904")
+	expectStatus(t, resp, body, http.StatusUnprocessableEntity)
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders", alice, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+
+	var orders []models.OrderListResponce
+	if err := json.Unmarshal([]byte(body), &orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderNumber != "12345678903" {
+		t.Errorf("список заказов: %+v", orders)
+	}
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders", bob, "", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+}
+
+func TestWithdraw(t *testing.T) {
+
+	server, db := testAPI(t)
+	alice := register(t, server, "alice")
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/balance/withdraw", alice, "application/json", `{"order":"2377225624","sum":751}`)
+	expectStatus(t, resp, body, http.StatusPaymentRequired)
+
+	userID, err := db.GetUserID(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AdjustBalance(context.Background(), userID, models.Points(100000), "пополнение для теста"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/balance/withdraw", alice, "application/json", `{"order":"2377225624","sum":751}`)
+	expectStatus(t, resp, body, http.StatusOK)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/balance/withdraw", alice, "application/json", `{"order":"2377225624","sum":1}`)
+	expectStatus(t, resp, body, http.StatusConflict)
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/balance", alice, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+
+	var balance models.BalanceResponce
+	if err := json.Unmarshal([]byte(body), &balance); err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != 24900 || balance.Withdrawn != 75100 {
+		t.Errorf("баланс: %+v", balance)
+	}
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/withdrawals", alice, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, `"order":"2377225624"`) {
+		t.Errorf("список списаний: %s", body)
+	}
+}
+
+func TestOrderListFilters(t *testing.T) {
+
+	server, db := testAPI(t)
+	alice := register(t, server, "alice")
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/orders", alice, "text/plain", "12345678903")
+	expectStatus(t, resp, body, http.StatusAccepted)
+
+	err := db.UpdateOrder(context.Background(), models.AccrualSystemResponce{Order: "12345678903", Status: constants.NotRelevant})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders?status=norelevant", alice, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+	if !strings.Contains(body, `"number":"12345678903"`) {
+		t.Errorf("список заказов в статусе NORELEVANT: %s", body)
+	}
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders?status=NEW,PROCESSED", alice, "", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders?status=UNKNOWN", alice, "", "")
+	expectStatus(t, resp, body, http.StatusBadRequest)
+
+	// границы периода со смещением сравниваются как моменты времени
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	from := url.QueryEscape(time.Now().Add(-time.Minute).In(zone).Format(time.RFC3339))
+	to := url.QueryEscape(time.Now().Add(time.Minute).In(zone).Format(time.RFC3339))
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders?from="+from+"&to="+to, alice, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+
+	resp, body = do(t, server, http.MethodGet, "/api/user/orders?from="+to, alice, "", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/health"
+	"go.uber.org/zap"
+)
+
+type HealthHandler struct {
+	config  *config.Config
+	logger  *zap.Logger
+	checker *health.Checker
+}
+
+func NewHealthHandler(cfg *config.Config, logger *zap.Logger, checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		config:  cfg,
+		logger:  logger,
+		checker: checker,
+	}
+}
+
+// Liveness сообщает, что процесс жив и обрабатывает запросы. Зависимости не проверяются.
+func (handler *HealthHandler) Liveness(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain")
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte("ok"))
+}
+
+// Readiness возвращает 503, если не пройдена хотя бы одна критичная проверка.
+func (handler *HealthHandler) Readiness(res http.ResponseWriter, req *http.Request) {
+
+	report := handler.checker.Check(req.Context())
+
+	res.Header().Set("Content-Type", "text/plain")
+
+	if report.Status == health.StatusFail {
+		failed := make([]string, 0, len(report.Checks))
+		for _, check := range report.Checks {
+			if check.Critical && check.Status == health.StatusFail {
+				failed = append(failed, check.Name)
+			}
+		}
+		res.WriteHeader(http.StatusServiceUnavailable)
+		res.Write([]byte("not ready: " + strings.Join(failed, ", ")))
+		return
+	}
+
+	res.WriteHeader(http.StatusOK)
+	res.Write([]byte(report.Status))
+}
+
+// Status возвращает подробное состояние каждой зависимости с временем проверки.
+func (handler *HealthHandler) Status(res http.ResponseWriter, req *http.Request) {
+
+	report := handler.checker.Check(req.Context())
+
+	res.Header().Set("Content-Type", "application/json")
+	if report.Status == health.StatusFail {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		res.WriteHeader(http.StatusOK)
+	}
+
+	enc := json.NewEncoder(res)
+	if err := enc.Encode(report); err != nil {
+		handler.logger.Error("ошибка при заполнении ответа", zap.Error(err))
+	}
+}
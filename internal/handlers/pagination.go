@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// размер страницы списков по умолчанию и максимальный
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parseListQuery разбирает параметры постраничного чтения списка:
+// limit, cursor, from и to (RFC 3339), sort (asc или desc) и, если withStatus, status
+// (через запятую или повтором параметра).
+func parseListQuery(req *http.Request, withStatus bool) (query models.ListQuery, err error) {
+
+	values := req.URL.Query()
+
+	query.Limit = defaultPageLimit
+	if value := values.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit < 1 || query.Limit > maxPageLimit {
+			return query, fmt.Errorf("limit должен быть числом от 1 до %d", maxPageLimit)
+		}
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := models.ParseListCursor(value)
+		if err != nil {
+			return query, err
+		}
+		query.Cursor = &cursor
+	}
+
+	if value := values.Get("from"); value != "" {
+		query.From, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("from должен быть в формате RFC 3339")
+		}
+	}
+
+	if value := values.Get("to"); value != "" {
+		query.To, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("to должен быть в формате RFC 3339")
+		}
+	}
+
+	switch values.Get("sort") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return query, fmt.Errorf("sort должен быть asc или desc")
+	}
+
+	if !withStatus {
+		if values.Has("status") {
+			return query, fmt.Errorf("фильтр по статусу не поддерживается")
+		}
+		return query, nil
+	}
+
+	for _, value := range values["status"] {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+			switch status {
+			case constants.New, constants.Registered, constants.Processing, constants.Processed, constants.Invalid, constants.NotRelevant:
+				query.Statuses = append(query.Statuses, status)
+			default:
+				return query, fmt.Errorf("неизвестный статус заказа: %q", status)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+// maxStatsDays - наибольший период статистики, в днях.
+const maxStatsDays = 366
+
+// parseDateRange разбирает параметры from и to (даты в формате 2006-01-02, обе включительно)
+// и возвращает период [from, to). По умолчанию период - последние days дней, включая сегодняшний.
+func parseDateRange(req *http.Request, days int) (from time.Time, to time.Time, err error) {
+
+	values := req.URL.Query()
+
+	to = time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if value := values.Get("to"); value != "" {
+		to, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return from, to, fmt.Errorf("to должен быть датой в формате 2006-01-02")
+		}
+		to = to.Add(24 * time.Hour)
+	}
+
+	from = to.AddDate(0, 0, -days)
+	if value := values.Get("from"); value != "" {
+		from, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return from, to, fmt.Errorf("from должен быть датой в формате 2006-01-02")
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from должен быть не позже to")
+	}
+	if to.Sub(from) > maxStatsDays*24*time.Hour {
+		return from, to, fmt.Errorf("период не должен превышать %d дней", maxStatsDays)
+	}
+
+	return from, to, nil
+}
+
+// setLinkHeader добавляет заголовок Link со ссылками на первую и следующую страницы.
+// Остальные параметры запроса сохраняются.
+func setLinkHeader(res http.ResponseWriter, req *http.Request, next string) {
+
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(req, ""))}
+	if next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(req, next)))
+	}
+
+	res.Header().Set("Link", strings.Join(links, ", "))
+}
+
+func pageURL(req *http.Request, cursor string) string {
+
+	values := req.URL.Query()
+	values.Del("cursor")
+	if cursor != "" {
+		values.Set("cursor", cursor)
+	}
+
+	u := url.URL{Path: req.URL.Path, RawQuery: values.Encode()}
+	return u.String()
+}
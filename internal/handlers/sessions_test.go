@@ -0,0 +1,139 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// login входит под пользователем login и возвращает токены новой сессии.
+func login(t *testing.T, server *httptest.Server, login string) models.AuthResponce {
+	t.Helper()
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/login", "", "application/json",
+		`{"login":"`+login+`","password":"secret123"}`)
+	expectStatus(t, resp, body, http.StatusOK)
+
+	return authResponse(t, body)
+}
+
+func refresh(t *testing.T, server *httptest.Server, refreshToken string) (*http.Response, string) {
+	t.Helper()
+
+	return do(t, server, http.MethodPost, "/api/user/token/refresh", "", "application/json",
+		`{"refresh_token":"`+refreshToken+`"}`)
+}
+
+func authResponse(t *testing.T, body string) models.AuthResponce {
+	t.Helper()
+
+	var auth models.AuthResponce
+	if err := json.Unmarshal([]byte(body), &auth); err != nil {
+		t.Fatal(err)
+	}
+	if auth.Token == "" || auth.RefreshToken == "" {
+		t.Fatalf("токены не переданы: %s", body)
+	}
+	return auth
+}
+
+func expectAuthorized(t *testing.T, server *httptest.Server, token string, want int) {
+	t.Helper()
+
+	resp, body := do(t, server, http.MethodGet, "/api/user/balance", "Bearer "+token, "", "")
+	expectStatus(t, resp, body, want)
+}
+
+func TestRefreshRotation(t *testing.T) {
+
+	server, _ := testAPI(t)
+	register(t, server, "alice")
+	first := login(t, server, "alice")
+
+	resp, body := refresh(t, server, first.RefreshToken)
+	expectStatus(t, resp, body, http.StatusOK)
+	second := authResponse(t, body)
+
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("токен обновления не заменён")
+	}
+	expectAuthorized(t, server, second.Token, http.StatusOK)
+
+	// предъявленный токен обновления больше не действует
+	resp, body = refresh(t, server, first.RefreshToken)
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+
+	resp, body = refresh(t, server, second.RefreshToken)
+	expectStatus(t, resp, body, http.StatusOK)
+
+	resp, body = refresh(t, server, "неизвестный")
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/token/refresh", "", "", "")
+	expectStatus(t, resp, body, http.StatusBadRequest)
+}
+
+func TestLogout(t *testing.T) {
+
+	server, _ := testAPI(t)
+	register(t, server, "alice")
+	current := login(t, server, "alice")
+	other := login(t, server, "alice")
+
+	resp, body := do(t, server, http.MethodPost, "/api/user/logout", "Bearer "+current.Token, "", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+
+	// токен доступа ещё не истёк, но сессия завершена
+	expectAuthorized(t, server, current.Token, http.StatusUnauthorized)
+	resp, body = refresh(t, server, current.RefreshToken)
+	expectStatus(t, resp, body, http.StatusUnauthorized)
+
+	// другие сессии пользователя продолжают действовать
+	expectAuthorized(t, server, other.Token, http.StatusOK)
+}
+
+func TestLogoutAll(t *testing.T) {
+
+	server, _ := testAPI(t)
+	register(t, server, "alice")
+	register(t, server, "bob")
+	current := login(t, server, "alice")
+	other := login(t, server, "alice")
+	bob := login(t, server, "bob")
+
+	resp, body := do(t, server, http.MethodGet, "/api/user/sessions", "Bearer "+current.Token, "", "")
+	expectStatus(t, resp, body, http.StatusOK)
+
+	var sessions []models.SessionResponce
+	if err := json.Unmarshal([]byte(body), &sessions); err != nil {
+		t.Fatal(err)
+	}
+	currentSessions := 0
+	for _, session := range sessions {
+		if session.Current {
+			currentSessions++
+		}
+	}
+	// сессии регистрации и двух входов
+	if len(sessions) != 3 || currentSessions != 1 {
+		t.Fatalf("сессии: %+v, ожидалось 3, из них одна текущая", sessions)
+	}
+
+	resp, body = do(t, server, http.MethodPost, "/api/user/logout/all", "Bearer "+current.Token, "", "")
+	expectStatus(t, resp, body, http.StatusNoContent)
+
+	for _, auth := range []models.AuthResponce{current, other} {
+		expectAuthorized(t, server, auth.Token, http.StatusUnauthorized)
+		resp, body = refresh(t, server, auth.RefreshToken)
+		expectStatus(t, resp, body, http.StatusUnauthorized)
+	}
+
+	// сессии других пользователей не затрагиваются
+	expectAuthorized(t, server, bob.Token, http.StatusOK)
+
+	// после выхода можно войти снова
+	expectAuthorized(t, server, login(t, server, "alice").Token, http.StatusOK)
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/accrualservice"
+	"github.com/maryakotova/gophermart/internal/breaker"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+// состояния проверок и сервиса в целом
+const (
+	StatusOK          = "ok"
+	StatusThrottled   = "throttled"
+	StatusCircuitOpen = "circuit-open"
+	StatusFail        = "fail"
+	StatusDegraded    = "degraded"
+)
+
+// checkTimeout ограничивает время одной проверки, чтобы зависшая зависимость не задерживала ответ.
+const checkTimeout = 2 * time.Second
+
+// Storage - часть хранилища, необходимая для проверки его состояния.
+type Storage interface {
+	Ping(ctx context.Context) error
+	PendingMigrations(ctx context.Context) (pending int, err error)
+}
+
+type check struct {
+	name     string
+	critical bool
+	run      func(ctx context.Context) (status string, details map[string]any, err error)
+}
+
+// Checker проверяет зависимости сервиса. Хранилище и миграции критичны: без них сервис
+// не готов принимать запросы. Система расчёта начислений некритична: пока она недоступна,
+// заказы копятся в очереди, а остальные запросы обслуживаются.
+type Checker struct {
+	config    *config.Config
+	logger    *zap.Logger
+	checks    []check
+	startedAt time.Time
+}
+
+func NewChecker(cfg *config.Config, logger *zap.Logger, storage Storage, accrual *accrualservice.AccrualService) *Checker {
+
+	c := &Checker{
+		config:    cfg,
+		logger:    logger,
+		startedAt: time.Now(),
+	}
+
+	c.checks = []check{
+		{name: "storage", critical: true, run: func(ctx context.Context) (string, map[string]any, error) {
+			return StatusOK, nil, storage.Ping(ctx)
+		}},
+		{name: "migrations", critical: true, run: func(ctx context.Context) (string, map[string]any, error) {
+			pending, err := storage.PendingMigrations(ctx)
+			if err != nil {
+				return StatusFail, nil, err
+			}
+			details := map[string]any{"pending": pending}
+			if pending > 0 {
+				return StatusFail, details, nil
+			}
+			return StatusOK, details, nil
+		}},
+		{name: "accrual", critical: false, run: func(ctx context.Context) (string, map[string]any, error) {
+			circuit := accrual.BreakerState()
+			details := map[string]any{"circuit": circuit.State.String(), "failures": circuit.Failures}
+
+			// к системе расчёта проверка не обращается: её лимит запросов расходует воркер,
+			// поэтому состояние определяется по ограничению, предохранителю и последнему запросу
+			if state := accrual.ThrottleState(); state.Throttled {
+				details["until"] = state.Until.Format(time.RFC3339)
+				details["rate_limit"] = state.RateLimit
+				return StatusThrottled, details, nil
+			}
+			if circuit.State == breaker.Open {
+				details["retry_at"] = circuit.RetryAt.Format(time.RFC3339)
+				return StatusCircuitOpen, details, nil
+			}
+
+			last := accrual.LastRequest()
+			if last.At.IsZero() {
+				return StatusOK, details, nil
+			}
+			details["last_request_at"] = last.At.Format(time.RFC3339)
+			details["last_status"] = last.Status
+			if last.Error != "" {
+				return StatusFail, details, errors.New(last.Error)
+			}
+
+			return StatusOK, details, nil
+		}},
+	}
+
+	return c
+}
+
+// Check выполняет все проверки параллельно и возвращает сводное состояние.
+func (c *Checker) Check(ctx context.Context) models.HealthResponce {
+
+	results := make([]models.HealthCheck, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	status := StatusOK
+	for _, result := range results {
+		if result.Status != StatusFail && result.Status != StatusCircuitOpen {
+			continue
+		}
+		if result.Critical {
+			status = StatusFail
+			break
+		}
+		status = StatusDegraded
+	}
+
+	return models.HealthResponce{
+		Status:    status,
+		StartedAt: c.startedAt.Format(time.RFC3339),
+		Uptime:    time.Since(c.startedAt).Round(time.Second).String(),
+		Checks:    results,
+	}
+}
+
+func (c *Checker) run(ctx context.Context, chk check) models.HealthCheck {
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	status, details, err := chk.run(ctx)
+	latency := time.Since(start)
+
+	result := models.HealthCheck{
+		Name:      chk.name,
+		Status:    status,
+		Critical:  chk.critical,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		Details:   details,
+	}
+
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+		c.logger.Warn("проверка зависимости не пройдена", zap.String("check", chk.name), zap.Error(err))
+	}
+
+	return result
+}
@@ -0,0 +1,34 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// New создаёт HTTP-клиент с ограничением времени запроса и пулом соединений.
+// Клиент создаётся один раз и переиспользуется: так соединения с сервером не открываются заново на каждый запрос.
+func New(timeout time.Duration, maxConnsPerHost int) *http.Client {
+
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
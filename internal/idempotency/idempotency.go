@@ -0,0 +1,269 @@
+// Package idempotency позволяет клиенту безопасно повторять изменяющие запросы: ответ на запрос
+// с заголовком Idempotency-Key сохраняется, и повтор с тем же ключом получает его без повторного выполнения.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/authutils"
+	"github.com/maryakotova/gophermart/internal/clock"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+const (
+	maxKeyLength = 255
+	maxBodyBytes = 1 << 20
+	// staleTimeout - время, после которого незавершённый запрос считается прерванным (например,
+	// из-за перезапуска сервиса), и ключ может занять повторный запрос.
+	staleTimeout = time.Minute
+	// purgeInterval - как часто удаляются истёкшие ключи.
+	purgeInterval = 10 * time.Minute
+)
+
+// Store хранит ключи идемпотентности и сохранённые ответы.
+type Store interface {
+	BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (stored models.IdempotencyRecord, created bool, err error)
+	CompleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error
+	DeleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error
+	PurgeIdempotentRequests(ctx context.Context, before time.Time) (deleted int64, err error)
+}
+
+// Keeper обрабатывает заголовок Idempotency-Key. Ключ действует в пределах пользователя в течение ttl.
+// Повтор с тем же ключом и тем же запросом получает сохранённый ответ, с тем же ключом и другим
+// запросом - 422, а пока первый запрос выполняется - 409. Ответы с ошибкой сервера не сохраняются.
+type Keeper struct {
+	store  Store
+	clock  clock.Clock
+	logger *zap.Logger
+	ttl    time.Duration
+
+	mtx       sync.Mutex
+	lastPurge time.Time
+}
+
+func NewKeeper(cfg *config.Config, logger *zap.Logger, store Store, clk clock.Clock) *Keeper {
+
+	ttl := cfg.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
+	return &Keeper{
+		store:  store,
+		clock:  clk,
+		logger: logger,
+		ttl:    ttl,
+	}
+}
+
+// Middleware применяет ключ идемпотентности к маршруту. Должен стоять после authutils.Middleware.
+func (k *Keeper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key := r.Header.Get(HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !validKey(key) {
+			http.Error(w, "некорректный заголовок Idempotency-Key: допускается от 1 до 255 печатных ASCII-символов", http.StatusBadRequest)
+			return
+		}
+
+		identity, ok := authutils.IdentityFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+		if err != nil {
+			http.Error(w, "ошибка при чтении тела запроса", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxBodyBytes {
+			http.Error(w, "тело запроса слишком большое", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// время округляется до точности TIMESTAMPTZ в Postgres: по нему запрос находит свою запись
+		now := k.clock.Now().Truncate(time.Microsecond)
+		record := models.IdempotencyRecord{
+			UserID:      identity.UserID,
+			Key:         key,
+			Fingerprint: fingerprint(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(k.ttl),
+		}
+
+		k.purge(r.Context(), now)
+
+		stored, created, err := k.store.BeginIdempotentRequest(r.Context(), record, now.Add(-staleTimeout))
+		if err != nil {
+			k.logger.Error("ошибка при проверке ключа идемпотентности", zap.Error(err))
+			http.Error(w, "ошибка при проверке ключа идемпотентности", http.StatusInternalServerError)
+			return
+		}
+
+		if !created {
+			k.replay(w, stored, record)
+			return
+		}
+
+		k.execute(w, r, next, record)
+	})
+}
+
+// replay отвечает на повтор запроса с уже использованным ключом.
+func (k *Keeper) replay(w http.ResponseWriter, stored models.IdempotencyRecord, record models.IdempotencyRecord) {
+
+	if stored.Fingerprint != record.Fingerprint {
+		http.Error(w, "ключ идемпотентности уже использован для другого запроса", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if stored.Status == 0 {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "запрос с этим ключом идемпотентности ещё выполняется", http.StatusConflict)
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// execute выполняет запрос и сохраняет ответ. Если обработчик ответил ошибкой сервера или
+// завершился паникой, ключ освобождается, чтобы клиент мог повторить запрос.
+func (k *Keeper) execute(w http.ResponseWriter, r *http.Request, next http.Handler, record models.IdempotencyRecord) {
+
+	// ответ сохраняется и при разрыве соединения клиентом: именно тогда клиент и повторит запрос
+	ctx := context.WithoutCancel(r.Context())
+	recorder := &responseRecorder{ResponseWriter: w}
+	completed := false
+
+	defer func() {
+		if completed {
+			return
+		}
+		if err := k.store.DeleteIdempotentRequest(ctx, record); err != nil {
+			k.logger.Error("ошибка при освобождении ключа идемпотентности", zap.Error(err))
+		}
+	}()
+
+	next.ServeHTTP(recorder, r)
+
+	if recorder.status() >= http.StatusInternalServerError {
+		return
+	}
+
+	record.Status = recorder.status()
+	record.ContentType = recorder.Header().Get("Content-Type")
+	record.Body = recorder.body.Bytes()
+
+	if err := k.store.CompleteIdempotentRequest(ctx, record); err != nil {
+		k.logger.Error("ошибка при сохранении ответа по ключу идемпотентности", zap.Error(err))
+		return
+	}
+	completed = true
+}
+
+// purge удаляет истёкшие ключи не чаще раза в purgeInterval, не задерживая запрос.
+func (k *Keeper) purge(ctx context.Context, now time.Time) {
+
+	k.mtx.Lock()
+	if now.Sub(k.lastPurge) < purgeInterval {
+		k.mtx.Unlock()
+		return
+	}
+	k.lastPurge = now
+	k.mtx.Unlock()
+
+	go func() {
+		deleted, err := k.store.PurgeIdempotentRequests(context.WithoutCancel(ctx), now)
+		if err != nil {
+			k.logger.Error("ошибка при удалении истёкших ключей идемпотентности", zap.Error(err))
+			return
+		}
+		if deleted > 0 {
+			k.logger.Info("удалены истёкшие ключи идемпотентности", zap.Int64("deleted", deleted))
+		}
+	}()
+}
+
+// fingerprint отличает запросы с одним ключом: метод, путь с параметрами запроса и тело.
+func fingerprint(r *http.Request, body []byte) string {
+	target := r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + target + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder передаёт ответ клиенту и одновременно запоминает его.
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
@@ -0,0 +1,332 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/authutils"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/idempotency"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"go.uber.org/zap"
+)
+
+const testTTL = time.Hour
+
+// fakeClock - часы, которые идут только по команде теста.
+type fakeClock struct {
+	mtx sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type testEnv struct {
+	store   *memory.MemoryStorage
+	clock   *fakeClock
+	tokens  *authutils.TokenManager
+	handler http.Handler
+
+	// next вызывается вместо обработчика маршрута; calls - количество вызовов
+	mtx   sync.Mutex
+	next  http.HandlerFunc
+	calls int
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	cfg := &config.Config{IdempotencyTTL: testTTL}
+
+	tokens, err := authutils.NewTokenManager(cfg, zap.NewNop(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &testEnv{
+		store:  memory.NewMemoryStorage(cfg, zap.NewNop()),
+		clock:  &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		tokens: tokens,
+		next: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("создано"))
+		},
+	}
+
+	keeper := idempotency.NewKeeper(cfg, zap.NewNop(), env.store, env.clock)
+	env.handler = tokens.Middleware(keeper.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env.mtx.Lock()
+		env.calls++
+		next := env.next
+		env.mtx.Unlock()
+		next(w, r)
+	})))
+
+	return env
+}
+
+func (env *testEnv) setNext(next http.HandlerFunc) {
+	env.mtx.Lock()
+	defer env.mtx.Unlock()
+	env.next = next
+}
+
+func (env *testEnv) expectCalls(t *testing.T, want int) {
+	t.Helper()
+
+	env.mtx.Lock()
+	defer env.mtx.Unlock()
+	if env.calls != want {
+		t.Fatalf("обработчик вызван %d раз, ожидалось %d", env.calls, want)
+	}
+}
+
+// request отправляет запрос пользователя userID с ключом key.
+func (env *testEnv) request(t *testing.T, userID int, key string, target string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, _, err := env.tokens.IssueToken(userID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set(idempotency.HeaderKey, key)
+	}
+
+	rec := httptest.NewRecorder()
+	env.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("код %d, ожидался %d: %s", rec.Code, status, rec.Body.String())
+	}
+}
+
+func TestReplay(t *testing.T) {
+
+	env := newTestEnv(t)
+
+	rec := env.request(t, 1, "k1", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`)
+	expectStatus(t, rec, http.StatusCreated)
+	if rec.Header().Get(idempotency.HeaderReplayed) != "" {
+		t.Error("первый ответ помечен как повтор")
+	}
+
+	rec = env.request(t, 1, "k1", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`)
+	expectStatus(t, rec, http.StatusCreated)
+	if rec.Header().Get(idempotency.HeaderReplayed) != "true" {
+		t.Errorf("заголовок %s = %q, ожидалось true", idempotency.HeaderReplayed, rec.Header().Get(idempotency.HeaderReplayed))
+	}
+	if rec.Body.String() != "создано" || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("повтор вернул %q (%s), ожидался сохранённый ответ", rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+	env.expectCalls(t, 1)
+
+	// ключ действует в пределах пользователя
+	expectStatus(t, env.request(t, 2, "k1", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`), http.StatusCreated)
+	env.expectCalls(t, 2)
+
+	// запросы без ключа не сохраняются
+	expectStatus(t, env.request(t, 1, "", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`), http.StatusCreated)
+	expectStatus(t, env.request(t, 1, "", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`), http.StatusCreated)
+	env.expectCalls(t, 4)
+}
+
+func TestDifferentRequest(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{name: "другое тело", target: "/api/user/orders", body: "79927398713"},
+		{name: "другой путь", target: "/api/user/balance/withdraw", body: "12345678903"},
+		{name: "другие параметры запроса", target: "/api/user/orders?limit=5", body: "12345678903"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+
+			expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "12345678903"), http.StatusCreated)
+			expectStatus(t, env.request(t, 1, "k1", tt.target, tt.body), http.StatusUnprocessableEntity)
+			env.expectCalls(t, 1)
+		})
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+
+	env := newTestEnv(t)
+
+	expectStatus(t, env.request(t, 1, strings.Repeat("k", 256), "/api/user/orders", "12345678903"), http.StatusBadRequest)
+	expectStatus(t, env.request(t, 1, "ключ", "/api/user/orders", "12345678903"), http.StatusBadRequest)
+	env.expectCalls(t, 0)
+}
+
+func TestInFlight(t *testing.T) {
+
+	env := newTestEnv(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	env.setNext(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- env.request(t, 1, "k1", "/api/user/orders", "12345678903")
+	}()
+	<-started
+
+	rec := env.request(t, 1, "k1", "/api/user/orders", "12345678903")
+	expectStatus(t, rec, http.StatusConflict)
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("заголовок Retry-After = %q, ожидалось 1", rec.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	expectStatus(t, <-done, http.StatusAccepted)
+
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "12345678903"), http.StatusAccepted)
+	env.expectCalls(t, 1)
+}
+
+func TestStaleRequest(t *testing.T) {
+
+	env := newTestEnv(t)
+
+	// запись без ответа остаётся, если сервис перезапустился во время выполнения запроса
+	now := env.clock.Now()
+	_, _, err := env.store.BeginIdempotentRequest(context.Background(), models.IdempotencyRecord{
+		UserID: 1, Key: "k1", Fingerprint: "прерванный запрос", CreatedAt: now, ExpiresAt: now.Add(testTTL),
+	}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "12345678903"), http.StatusUnprocessableEntity)
+
+	env.clock.Advance(time.Minute + time.Second)
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "12345678903"), http.StatusCreated)
+	env.expectCalls(t, 1)
+}
+
+func TestReleaseOnFailure(t *testing.T) {
+
+	tests := []struct {
+		name string
+		next http.HandlerFunc
+	}{
+		{
+			name: "ошибка сервера",
+			next: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "ошибка", http.StatusServiceUnavailable)
+			},
+		},
+		{
+			name: "паника",
+			next: func(w http.ResponseWriter, r *http.Request) {
+				panic("сбой обработчика")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.setNext(tt.next)
+
+			func() {
+				defer func() { recover() }()
+				env.request(t, 1, "k1", "/api/user/orders", "12345678903")
+			}()
+
+			// ключ освобождён, и повтор выполняется заново
+			env.setNext(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})
+			expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "12345678903"), http.StatusAccepted)
+			env.expectCalls(t, 2)
+		})
+	}
+}
+
+func TestClientErrorStored(t *testing.T) {
+
+	env := newTestEnv(t)
+	env.setNext(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "недостаточно средств", http.StatusPaymentRequired)
+	})
+
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`), http.StatusPaymentRequired)
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/balance/withdraw", `{"order":"2377225624","sum":751}`), http.StatusPaymentRequired)
+	env.expectCalls(t, 1)
+}
+
+func TestExpiry(t *testing.T) {
+
+	env := newTestEnv(t)
+	ctx := context.Background()
+	first := env.clock.Now()
+
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "12345678903"), http.StatusCreated)
+
+	env.clock.Advance(testTTL - time.Second)
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "79927398713"), http.StatusUnprocessableEntity)
+
+	// после истечения ключ можно использовать для нового запроса
+	env.clock.Advance(time.Second)
+	expectStatus(t, env.request(t, 1, "k1", "/api/user/orders", "79927398713"), http.StatusCreated)
+	env.expectCalls(t, 2)
+
+	// истёкшие ключи удаляются при очередном запросе в фоне
+	expectStatus(t, env.request(t, 1, "k2", "/api/user/orders", "12345678903"), http.StatusCreated)
+	env.clock.Advance(testTTL)
+	expectStatus(t, env.request(t, 1, "k3", "/api/user/orders", "12345678903"), http.StatusCreated)
+
+	// пока запись k2 не удалена, она занята на момент создания
+	probe := models.IdempotencyRecord{UserID: 1, Key: "k2", Fingerprint: "проверка", CreatedAt: first, ExpiresAt: first.Add(testTTL)}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, created, err := env.store.BeginIdempotentRequest(ctx, probe, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("истёкший ключ не удалён")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -34,7 +34,8 @@ func Initialize(level string) (logger *zap.Logger, err error) {
 	}
 	cfg := zap.NewProductionConfig()
 	cfg.Level = lvl
-	Log, err := cfg.Build()
+	// присваиваем глобальному Log, а не новой переменной: иначе WithLogging пишет в zap.NewNop()
+	Log, err = cfg.Build()
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +70,11 @@ func WithLogging(h http.HandlerFunc) http.HandlerFunc {
 
 		duration := time.Since(start)
 
+		// обработчик, не вызвавший WriteHeader, отвечает статусом 200
+		if responseData.status == 0 {
+			responseData.status = http.StatusOK
+		}
+
 		Log.Info("got incoming HTTP request",
 			zap.String("uri", r.RequestURI),
 			zap.String("method", r.Method),
@@ -78,3 +84,8 @@ func WithLogging(h http.HandlerFunc) http.HandlerFunc {
 		)
 	}
 }
+
+// Middleware - вариант WithLogging для подключения к роутеру через Use.
+func Middleware(next http.Handler) http.Handler {
+	return WithLogging(next.ServeHTTP)
+}
@@ -0,0 +1,198 @@
+package loginguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/clock"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+// ipAttemptsFactor - во сколько раз лимиты попыток для IP-адреса выше, чем для логина:
+// за одним адресом (NAT, прокси) может находиться много пользователей.
+const ipAttemptsFactor = 5
+
+// Store хранит счётчики неудачных попыток входа и журнал блокировок.
+type Store interface {
+	GetLoginAttempts(ctx context.Context, key string) (attempts models.LoginAttempts, err error)
+	RegisterLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (attempts models.LoginAttempts, err error)
+	DelayLogin(ctx context.Context, key string, until time.Time) error
+	LockLogin(ctx context.Context, lockout models.LoginLockout) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	UnlockLogin(ctx context.Context, key string, at time.Time) error
+	GetLoginLockouts(ctx context.Context, key string, limit int) (lockouts []models.LoginLockout, err error)
+}
+
+// Policy задаёт реакцию на неудачные попытки входа: первые FreeAttempts попыток проходят без задержки,
+// затем вход откладывается на BaseDelay, 2*BaseDelay, 4*BaseDelay и т.д., а после MaxAttempts
+// попыток блокируется на Lockout с записью события в журнал.
+type Policy struct {
+	FreeAttempts int
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	Lockout      time.Duration
+	Window       time.Duration
+}
+
+// Guard защищает вход от подбора пароля, учитывая неудачные попытки по логину и по IP-адресу.
+type Guard struct {
+	store    Store
+	clock    clock.Clock
+	logger   *zap.Logger
+	loginPol Policy
+	ipPol    Policy
+}
+
+func NewGuard(cfg *config.Config, logger *zap.Logger, store Store, clk clock.Clock) *Guard {
+
+	policy := Policy{
+		FreeAttempts: cfg.LoginFreeAttempts,
+		MaxAttempts:  cfg.LoginMaxAttempts,
+		BaseDelay:    cfg.LoginBaseDelay,
+		Lockout:      cfg.LoginLockout,
+		Window:       cfg.LoginAttemptWindow,
+	}
+
+	ipPolicy := policy
+	ipPolicy.FreeAttempts *= ipAttemptsFactor
+	ipPolicy.MaxAttempts *= ipAttemptsFactor
+
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
+	return &Guard{
+		store:    store,
+		clock:    clk,
+		logger:   logger,
+		loginPol: policy,
+		ipPol:    ipPolicy,
+	}
+}
+
+// Check возвращает, сколько осталось ждать до следующей попытки входа (0 - вход разрешён).
+func (g *Guard) Check(ctx context.Context, login string, ip string) (retryAfter time.Duration, err error) {
+
+	now := g.clock.Now()
+
+	for _, key := range []string{loginKey(login), ipKey(ip)} {
+		attempts, err := g.store.GetLoginAttempts(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := attempts.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	return retryAfter, nil
+}
+
+// Failure учитывает неудачную попытку входа и возвращает время до следующей разрешённой попытки.
+func (g *Guard) Failure(ctx context.Context, login string, ip string) (retryAfter time.Duration, err error) {
+
+	wait, err := g.registerFailure(ctx, loginKey(login), g.loginPol)
+	if err != nil {
+		return 0, err
+	}
+	retryAfter = wait
+
+	wait, err = g.registerFailure(ctx, ipKey(ip), g.ipPol)
+	if err != nil {
+		return 0, err
+	}
+	if wait > retryAfter {
+		retryAfter = wait
+	}
+
+	return retryAfter, nil
+}
+
+// Success сбрасывает счётчик попыток по логину. Счётчик по IP-адресу не сбрасывается,
+// иначе успешный вход в собственную учётную запись позволял бы продолжать подбор.
+func (g *Guard) Success(ctx context.Context, login string) error {
+	return g.store.ResetLoginAttempts(ctx, loginKey(login))
+}
+
+// Unlock снимает блокировку с логина.
+func (g *Guard) Unlock(ctx context.Context, login string) error {
+	return g.store.UnlockLogin(ctx, loginKey(login), g.clock.Now())
+}
+
+// UnlockIP снимает блокировку с IP-адреса.
+func (g *Guard) UnlockIP(ctx context.Context, ip string) error {
+	return g.store.UnlockLogin(ctx, ipKey(ip), g.clock.Now())
+}
+
+// Lockouts возвращает последние блокировки логина или все блокировки, если логин не задан.
+func (g *Guard) Lockouts(ctx context.Context, login string, limit int) ([]models.LoginLockout, error) {
+	key := ""
+	if login != "" {
+		key = loginKey(login)
+	}
+	return g.store.GetLoginLockouts(ctx, key, limit)
+}
+
+func (g *Guard) registerFailure(ctx context.Context, key string, policy Policy) (time.Duration, error) {
+
+	now := g.clock.Now()
+
+	attempts, err := g.store.RegisterLoginFailure(ctx, key, now, policy.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	if policy.MaxAttempts > 0 && attempts.Failures >= policy.MaxAttempts {
+		lockout := models.LoginLockout{
+			Key:         key,
+			Failures:    attempts.Failures,
+			LockedAt:    now,
+			LockedUntil: now.Add(policy.Lockout),
+		}
+		if err := g.store.LockLogin(ctx, lockout); err != nil {
+			return 0, err
+		}
+		g.logger.Warn("вход заблокирован после неудачных попыток",
+			zap.String("key", key), zap.Int("failures", attempts.Failures), zap.Time("lockedUntil", lockout.LockedUntil))
+		return policy.Lockout, nil
+	}
+
+	delay := policy.delay(attempts.Failures)
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	if err := g.store.DelayLogin(ctx, key, now.Add(delay)); err != nil {
+		return 0, err
+	}
+
+	return delay, nil
+}
+
+// delay вычисляет задержку после failures неудачных попыток подряд.
+func (p Policy) delay(failures int) time.Duration {
+
+	if failures <= p.FreeAttempts || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if p.Lockout > 0 && delay >= p.Lockout {
+			return p.Lockout
+		}
+	}
+
+	return delay
+}
+
+func loginKey(login string) string {
+	return "login:" + login
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
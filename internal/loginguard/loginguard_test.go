@@ -0,0 +1,221 @@
+package loginguard_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/loginguard"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"go.uber.org/zap"
+)
+
+// fakeClock - часы, которые идут только по команде теста.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+const (
+	testLogin = "user"
+	testIP    = "192.0.2.1"
+)
+
+func testGuard(t *testing.T) (*loginguard.Guard, *fakeClock) {
+	t.Helper()
+
+	cfg := &config.Config{
+		LoginFreeAttempts:  3,
+		LoginMaxAttempts:   8,
+		LoginBaseDelay:     time.Second,
+		LoginLockout:       time.Hour,
+		LoginAttemptWindow: 15 * time.Minute,
+	}
+	log := zap.NewNop()
+	clk := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	return loginguard.NewGuard(cfg, log, memory.NewMemoryStorage(cfg, log), clk), clk
+}
+
+func expectRetryAfter(t *testing.T, guard *loginguard.Guard, want time.Duration) {
+	t.Helper()
+
+	got, err := guard.Check(context.Background(), testLogin, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("Check() = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestExponentialDelay(t *testing.T) {
+
+	guard, clk := testGuard(t)
+	ctx := context.Background()
+
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for i, delay := range want {
+		got, err := guard.Failure(ctx, testLogin, testIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != delay {
+			t.Fatalf("попытка %d: задержка %v, ожидалась %v", i+1, got, delay)
+		}
+
+		expectRetryAfter(t, guard, delay)
+		clk.Advance(delay / 2)
+		expectRetryAfter(t, guard, delay-delay/2)
+		clk.Advance(delay - delay/2)
+		expectRetryAfter(t, guard, 0)
+	}
+}
+
+func TestLockoutThreshold(t *testing.T) {
+
+	guard, clk := testGuard(t)
+	ctx := context.Background()
+
+	var retryAfter time.Duration
+	for i := 0; i < 8; i++ {
+		clk.Advance(retryAfter)
+		var err error
+		retryAfter, err = guard.Failure(ctx, testLogin, testIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if retryAfter != time.Hour {
+		t.Fatalf("после последней попытки задержка %v, ожидалась блокировка на %v", retryAfter, time.Hour)
+	}
+	expectRetryAfter(t, guard, time.Hour)
+
+	lockouts, err := guard.Lockouts(ctx, testLogin, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 1 {
+		t.Fatalf("записано %d блокировок, ожидалась 1", len(lockouts))
+	}
+	if lockouts[0].Failures != 8 || !lockouts[0].LockedUntil.Equal(clk.Now().Add(time.Hour)) {
+		t.Fatalf("неверная запись о блокировке: %+v", lockouts[0])
+	}
+
+	clk.Advance(59 * time.Minute)
+	expectRetryAfter(t, guard, time.Minute)
+	clk.Advance(time.Minute)
+	expectRetryAfter(t, guard, 0)
+}
+
+func TestWindowExpiry(t *testing.T) {
+
+	guard, clk := testGuard(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := guard.Failure(ctx, testLogin, testIP); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// после паузы длиннее окна счётчик начинается заново, и попытка снова бесплатная
+	clk.Advance(15*time.Minute + time.Second)
+
+	retryAfter, err := guard.Failure(ctx, testLogin, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter != 0 {
+		t.Fatalf("после истечения окна задержка %v, ожидалось 0", retryAfter)
+	}
+
+	// внутри окна счётчик продолжает расти
+	for i := 0; i < 2; i++ {
+		if _, err := guard.Failure(ctx, testLogin, testIP); err != nil {
+			t.Fatal(err)
+		}
+	}
+	retryAfter, err = guard.Failure(ctx, testLogin, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter != time.Second {
+		t.Fatalf("четвёртая попытка в окне: задержка %v, ожидалась %v", retryAfter, time.Second)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+
+	guard, clk := testGuard(t)
+	ctx := context.Background()
+
+	var retryAfter time.Duration
+	for i := 0; i < 8; i++ {
+		clk.Advance(retryAfter)
+		var err error
+		retryAfter, err = guard.Failure(ctx, testLogin, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectRetryAfter(t, guard, time.Hour)
+
+	clk.Advance(10 * time.Minute)
+	if err := guard.Unlock(ctx, testLogin); err != nil {
+		t.Fatal(err)
+	}
+	expectRetryAfter(t, guard, 0)
+
+	lockouts, err := guard.Lockouts(ctx, testLogin, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 1 || !lockouts[0].UnlockedAt.Equal(clk.Now()) {
+		t.Fatalf("блокировка не отмечена как снятая: %+v", lockouts)
+	}
+
+	// после разблокировки счётчик сброшен: первые попытки снова без задержки
+	retryAfter, err = guard.Failure(ctx, testLogin, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter != 0 {
+		t.Fatalf("после разблокировки задержка %v, ожидалось 0", retryAfter)
+	}
+}
+
+func TestSuccessKeepsIPCounter(t *testing.T) {
+
+	guard, _ := testGuard(t)
+	ctx := context.Background()
+
+	// лимиты для IP-адреса в пять раз выше: 15 бесплатных попыток, затем задержка
+	for i := 0; i < 15; i++ {
+		if _, err := guard.Failure(ctx, testLogin, testIP); err != nil {
+			t.Fatal(err)
+		}
+		if err := guard.Success(ctx, testLogin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectRetryAfter(t, guard, 0)
+
+	retryAfter, err := guard.Failure(ctx, "other", testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter != time.Second {
+		t.Fatalf("задержка по IP-адресу %v, ожидалась %v", retryAfter, time.Second)
+	}
+	expectRetryAfter(t, guard, time.Second)
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+)
+
+// Default - реестр метрик сервиса, который отдаётся на /metrics.
+var Default = NewRegistry()
+
+var (
+	HTTPRequests = Default.NewCounterVec("gophermart_http_requests_total",
+		"Количество HTTP-запросов по маршруту и коду ответа.", "method", "route", "status")
+	HTTPDuration = Default.NewHistogramVec("gophermart_http_request_duration_seconds",
+		"Длительность обработки HTTP-запросов.", DefaultBuckets, "method", "route")
+
+	StorageDuration = Default.NewHistogramVec("gophermart_storage_query_duration_seconds",
+		"Длительность обращений к хранилищу по методу.", DefaultBuckets, "method")
+	StorageErrors = Default.NewCounterVec("gophermart_storage_errors_total",
+		"Количество ошибок хранилища по методу.", "method")
+
+	AccrualRequests = Default.NewCounterVec("gophermart_accrual_requests_total",
+		"Количество запросов к системе расчёта начислений по коду ответа (error - ответ не получен).", "status")
+	AccrualDuration = Default.NewHistogramVec("gophermart_accrual_request_duration_seconds",
+		"Длительность запросов к системе расчёта начислений.", DefaultBuckets)
+
+	OrdersUploaded = Default.NewCounterVec("gophermart_orders_uploaded_total",
+		"Количество принятых к расчёту заказов.")
+	OrdersProcessed = Default.NewCounterVec("gophermart_orders_processed_total",
+		"Количество заказов, получивших финальный статус, по статусу.", "status")
+	PointsAccrued = Default.NewCounterVec("gophermart_points_accrued_total",
+		"Сумма начисленных баллов.")
+	PointsWithdrawn = Default.NewCounterVec("gophermart_points_withdrawn_total",
+		"Сумма списанных баллов.")
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Middleware считает HTTP-запросы и их длительность. Маршрут берётся из шаблона chi
+// (например, /api/user/orders), чтобы количество серий не зависело от параметров запроса.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		HTTPRequests.Inc(r.Method, route, strconv.Itoa(status))
+		HTTPDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// ObserveStorage учитывает длительность и результат обращения к хранилищу.
+// Вызывается через defer в начале метода: defer metrics.ObserveStorage("Method", time.Now(), &err).
+// Ожидаемые ошибки бизнес-логики (customerrors) ошибками хранилища не считаются.
+func ObserveStorage(method string, start time.Time, err *error) {
+	StorageDuration.Observe(time.Since(start).Seconds(), method)
+
+	var myErr *customerrors.MyError
+	if err != nil && *err != nil && !errors.As(*err, &myErr) {
+		StorageErrors.Inc(method)
+	}
+}
@@ -0,0 +1,337 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets - границы корзин гистограмм длительности в секундах.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector - метрика, которую Registry умеет выводить в текстовом формате Prometheus.
+type collector interface {
+	name() string
+	write(ctx context.Context, w io.Writer) error
+}
+
+// Registry хранит метрики и отдаёт их в текстовом формате Prometheus (text exposition format 0.0.4).
+type Registry struct {
+	mtx        sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (r *Registry) register(c collector) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.collectors[c.name()]; ok {
+		panic(fmt.Sprintf("метрика %s уже зарегистрирована", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: newFamily(name, help, labels)}
+	r.register(c)
+	return c
+}
+
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{family: newFamily(name, help, labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+// NewGaugeFunc регистрирует метрику, значение которой вычисляется fn при каждом запросе метрик.
+func (r *Registry) NewGaugeFunc(name string, help string, fn func(ctx context.Context) (float64, error)) {
+	r.register(&gaugeFunc{family: newFamily(name, help, nil), fn: fn})
+}
+
+// WriteText выводит все метрики, упорядоченные по имени. Ошибка вычисления одной метрики
+// не мешает выводу остальных.
+func (r *Registry) WriteText(ctx context.Context, w io.Writer) error {
+
+	r.mtx.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := r.collectors
+	r.mtx.RUnlock()
+
+	sort.Strings(names)
+
+	var firstErr error
+	for _, name := range names {
+		if err := collectors[name].write(ctx, w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Handler отдаёт метрики по HTTP.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		r.WriteText(req.Context(), w)
+	})
+}
+
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func newFamily(name string, help string, labels []string) family {
+	return family{metricName: name, help: help, labels: labels}
+}
+
+func (f family) name() string {
+	return f.metricName
+}
+
+func (f family) header(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, escapeHelp(f.help), f.metricName, kind)
+	return err
+}
+
+// key склеивает значения меток в ключ для хранения серии.
+func (f family) key(labelValues []string) string {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("метрика %s: ожидается %d меток, передано %d", f.metricName, len(f.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// formatLabels формирует {a="1",b="2"}, добавляя к меткам серии дополнительную пару, если она задана.
+func (f family) formatLabels(labelValues []string, extraName string, extraValue string) string {
+
+	if len(f.labels) == 0 && extraName == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", label, escapeLabel(labelValues[i]))
+	}
+	if extraName != "" {
+		if len(f.labels) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, escapeLabel(extraValue))
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+type series struct {
+	labelValues []string
+}
+
+// CounterVec - счётчик с метками.
+type CounterVec struct {
+	family
+	mtx    sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	series
+	value float64
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add увеличивает счётчик на v. Отрицательные значения игнорируются: счётчик не может уменьшаться.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	key := c.key(labelValues)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.series == nil {
+		c.series = make(map[string]*counterSeries)
+	}
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{series: series{labelValues: append([]string(nil), labelValues...)}}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) write(ctx context.Context, w io.Writer) error {
+
+	if err := c.header(w, "counter"); err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// счётчик без меток выводится и до первого увеличения, чтобы rate() видел начальный ноль
+	if len(c.labels) == 0 && len(c.series) == 0 {
+		_, err := fmt.Fprintf(w, "%s 0\n", c.metricName)
+		return err
+	}
+
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.formatLabels(s.labelValues, "", ""), formatFloat(s.value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HistogramVec - гистограмма с метками.
+type HistogramVec struct {
+	family
+	buckets []float64
+	mtx     sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	series
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+
+	key := h.key(labelValues)
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.series == nil {
+		h.series = make(map[string]*histogramSeries)
+	}
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			series: series{labelValues: append([]string(nil), labelValues...)},
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(ctx context.Context, w io.Writer) error {
+
+	if err := h.header(w, "histogram"); err != nil {
+		return err
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.formatLabels(s.labelValues, "le", formatFloat(bound)), s.counts[i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.metricName, h.formatLabels(s.labelValues, "le", "+Inf"), s.count,
+			h.metricName, h.formatLabels(s.labelValues, "", ""), formatFloat(s.sum),
+			h.metricName, h.formatLabels(s.labelValues, "", ""), s.count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type gaugeFunc struct {
+	family
+	fn func(ctx context.Context) (float64, error)
+}
+
+func (g *gaugeFunc) write(ctx context.Context, w io.Writer) error {
+
+	value, err := g.fn(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось вычислить метрику %s: %w", g.metricName, err)
+	}
+
+	if err := g.header(w, "gauge"); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(value))
+
+	return err
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
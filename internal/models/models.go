@@ -1,50 +1,248 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type RegisterRequest struct {
 	Login    string `json:"login"`    // Имя пользователя
 	Password string `json:"password"` // Пароль
 }
 
+type AuthResponce struct {
+	Token            string `json:"token"`                        // Токен доступа
+	TokenType        string `json:"token_type"`                   // Тип токена для заголовка Authorization
+	ExpiresAt        string `json:"expires_at"`                   // Время окончания действия токена доступа
+	RefreshToken     string `json:"refresh_token,omitempty"`      // Токен обновления
+	RefreshExpiresAt string `json:"refresh_expires_at,omitempty"` // Время окончания действия токена обновления
+}
+
 type OrderList struct {
 	OrderNumber string
 	Status      string
-	Accrual     float64
+	Accrual     Points
 	UploadedAt  time.Time
 }
 
 type OrderListResponce struct {
-	OrderNumber string  `json:"number"`            // Номер заказа
-	Status      string  `json:"status"`            // Статус заказа
-	Accrural    float64 `json:"accrual,omitempty"` // Сумма начислений (опционально)
-	UploadedAt  string  `json:"uploaded_at"`       // Время загрузки
+	OrderNumber string `json:"number"`            // Номер заказа
+	Status      string `json:"status"`            // Статус заказа
+	Accrural    Points `json:"accrual,omitempty"` // Сумма начислений (опционально)
+	UploadedAt  string `json:"uploaded_at"`       // Время загрузки
+}
+
+type Order struct {
+	OrderNumber int64     // Номер заказа
+	UserID      int       // Владелец заказа
+	Status      string    // Текущий статус заказа
+	Accrual     Points    // Сумма начислений
+	UploadedAt  time.Time // Время загрузки
+}
+
+type OrderStatusEvent struct {
+	Status      string          // Статус, в который перешёл заказ
+	OccurredAt  time.Time       // Время перехода
+	Accrual     Points          // Сумма начислений в ответе системы расчёта
+	RawResponse json.RawMessage // Ответ системы расчёта, вызвавший переход (пустой для загрузки и ответа 204)
+}
+
+type OrderResponce struct {
+	OrderNumber string                     `json:"number"`            // Номер заказа
+	Status      string                     `json:"status"`            // Статус заказа
+	Accrural    Points                     `json:"accrual,omitempty"` // Сумма начислений (опционально)
+	UploadedAt  string                     `json:"uploaded_at"`       // Время загрузки
+	History     []OrderStatusEventResponce `json:"history"`           // Смены статуса от загрузки до текущего
+}
+
+type OrderStatusEventResponce struct {
+	Status     string `json:"status"`      // Статус заказа
+	OccurredAt string `json:"occurred_at"` // Время перехода в статус
+}
+
+type OrderTimelineEventResponce struct {
+	Status          string          `json:"status"`                     // Статус заказа
+	OccurredAt      string          `json:"occurred_at"`                // Время перехода в статус
+	SinceUpload     float64         `json:"since_upload_seconds"`       // Время от загрузки заказа, в секундах
+	SincePrevious   float64         `json:"since_previous_seconds"`     // Время в предыдущем статусе, в секундах
+	Accrual         Points          `json:"accrual,omitempty"`          // Сумма начислений (опционально)
+	AccrualResponse json.RawMessage `json:"accrual_response,omitempty"` // Ответ системы расчёта (опционально)
+}
+
+type OrderLatencyStats struct {
+	Day    time.Time     // День, в который заказы получили финальный статус
+	Orders int           // Количество обработанных заказов
+	Avg    time.Duration // Среднее время от загрузки до финального статуса
+	P50    time.Duration // Медиана
+	P95    time.Duration // 95-й процентиль
+	Max    time.Duration // Максимум
+}
+
+type OrderLatencyStatsResponce struct {
+	Day    string  `json:"day"`         // День в формате 2006-01-02
+	Orders int     `json:"orders"`      // Количество обработанных заказов
+	Avg    float64 `json:"avg_seconds"` // Среднее время обработки, в секундах
+	P50    float64 `json:"p50_seconds"` // Медиана, в секундах
+	P95    float64 `json:"p95_seconds"` // 95-й процентиль, в секундах
+	Max    float64 `json:"max_seconds"` // Максимум, в секундах
+}
+
+type BatchOrderResultResponce struct {
+	OrderNumber string `json:"number"`          // Номер заказа, как он передан в запросе
+	Result      string `json:"result"`          // accepted, duplicate-own, duplicate-other или invalid
+	Status      int    `json:"status"`          // Код ответа, который вернула бы загрузка одного заказа
+	Error       string `json:"error,omitempty"` // Причина отказа (опционально)
+}
+
+type BatchOrdersResponce struct {
+	Summary map[string]int             `json:"summary"` // Количество заказов по результатам
+	Results []BatchOrderResultResponce `json:"results"` // Результаты в порядке запроса
 }
 
 type BalanceResponce struct {
-	Balance   float64 `json:"current"`   // Текущая сумма баллов лояльности
-	Withdrawn float64 `json:"withdrawn"` // Сумма использованных за весь период регистрации баллов
+	Balance   Points `json:"current"`   // Текущая сумма баллов лояльности
+	Withdrawn Points `json:"withdrawn"` // Сумма использованных за весь период регистрации баллов
 }
 
 type WithdrawRequest struct {
-	OrderNumber string  `json:"order"` // Номер заказа
-	Sum         float64 `json:"sum"`   // Запрашиваемая сумма баллов для списания
+	OrderNumber string `json:"order"` // Номер заказа
+	Sum         Points `json:"sum"`   // Запрашиваемая сумма баллов для списания
 }
 
 type Withdrawals struct {
 	OrderNumber string
-	Sum         float64
+	Sum         Points
 	ProcessedAt time.Time
 }
 
 type WithdrawalsResponce struct {
-	OrderNumber string  `json:"order"`        // Номер заказа
-	Sum         float64 `json:"sum"`          // Списанное количество баллов
-	ProcessedAt string  `json:"processed_at"` // Время вывода средств
+	OrderNumber string `json:"order"`        // Номер заказа
+	Sum         Points `json:"sum"`          // Списанное количество баллов
+	ProcessedAt string `json:"processed_at"` // Время вывода средств
 }
 
 type AccrualSystemResponce struct {
-	Order   string  `json:"order"`             // Номер заказа
-	Status  string  `json:"status"`            // Статус заказа
-	Accrual float64 `json:"accrual,omitempty"` // Начисленные баллы
+	Order   string          `json:"order"`             // Номер заказа
+	Status  string          `json:"status"`            // Статус заказа
+	Accrual Points          `json:"accrual,omitempty"` // Начисленные баллы
+	Raw     json.RawMessage `json:"-"`                 // Тело ответа как есть, для истории заказа
+}
+
+// UnmarshalJSON округляет начисление до сотых: система расчёта может передать больше знаков
+// после запятой, и такой ответ не должен оставлять заказ в очереди навсегда.
+func (r *AccrualSystemResponce) UnmarshalJSON(data []byte) error {
+
+	var raw struct {
+		Order   string       `json:"order"`
+		Status  string       `json:"status"`
+		Accrual *json.Number `json:"accrual"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	r.Order = raw.Order
+	r.Status = raw.Status
+	r.Accrual = 0
+
+	if raw.Accrual != nil {
+		accrual, err := ParsePointsRounded(raw.Accrual.String())
+		if err != nil {
+			return err
+		}
+		r.Accrual = accrual
+	}
+
+	return nil
+}
+
+type OrderToProcess struct {
+	OrderNumber int64  // Номер заказа
+	Status      string // Текущий статус заказа
+	Accrual     Points // Текущая сумма начислений
+}
+
+type LedgerEntry struct {
+	EntryID     int64     // Идентификатор проводки
+	UserID      int       // Пользователь
+	Kind        string    // Вид операции: начисление, списание или корректировка
+	Amount      Points    // Сумма проводки: положительная для зачислений, отрицательная для списаний
+	OrderNumber int64     // Номер заказа (0, если операция не связана с заказом)
+	Comment     string    // Комментарий к операции
+	CreatedAt   time.Time // Время операции
+}
+
+type BalanceMismatch struct {
+	UserID        int    // Пользователь
+	Login         string // Логин пользователя
+	Balance       Points // Материализованный остаток
+	LedgerBalance Points // Остаток по журналу
+}
+
+type Session struct {
+	ID          int64     // Идентификатор сессии
+	UserID      int       // Пользователь
+	RefreshHash string    // SHA-256 от токена обновления
+	UserAgent   string    // User-Agent клиента
+	IP          string    // IP-адрес клиента
+	CreatedAt   time.Time // Время входа
+	LastSeenAt  time.Time // Время последнего обращения
+	ExpiresAt   time.Time // Время окончания действия токена обновления
+}
+
+type SessionResponce struct {
+	ID         int64  `json:"id"`           // Идентификатор сессии
+	UserAgent  string `json:"user_agent"`   // Устройство (User-Agent)
+	IP         string `json:"ip"`           // IP-адрес
+	CreatedAt  string `json:"created_at"`   // Время входа
+	LastSeenAt string `json:"last_seen_at"` // Время последнего обращения
+	Current    bool   `json:"current"`      // Текущая сессия
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"` // Токен обновления
+}
+
+type LoginAttempts struct {
+	Key           string    // Ключ учёта попыток: логин или IP-адрес
+	Failures      int       // Количество неудачных попыток подряд
+	LastFailureAt time.Time // Время последней неудачной попытки
+	LockedUntil   time.Time // До какого времени вход запрещён (нулевое значение - не запрещён)
+}
+
+type LoginLockout struct {
+	ID          int64     // Идентификатор события блокировки
+	Key         string    // Ключ учёта попыток: логин или IP-адрес
+	Failures    int       // Количество неудачных попыток, после которых вход заблокирован
+	LockedAt    time.Time // Время блокировки
+	LockedUntil time.Time // Время окончания блокировки
+	UnlockedAt  time.Time // Время ручной разблокировки (нулевое значение - не разблокирован)
+}
+
+type HealthCheck struct {
+	Name      string         `json:"name"`              // Название зависимости
+	Status    string         `json:"status"`            // Состояние: ok, throttled, circuit-open или fail
+	Critical  bool           `json:"critical"`          // Влияет ли состояние на готовность сервиса
+	LatencyMs float64        `json:"latency_ms"`        // Время проверки в миллисекундах
+	Error     string         `json:"error,omitempty"`   // Текст ошибки проверки
+	Details   map[string]any `json:"details,omitempty"` // Дополнительные сведения
+}
+
+type HealthResponce struct {
+	Status    string        `json:"status"`     // Общее состояние: ok, degraded или fail
+	StartedAt string        `json:"started_at"` // Время запуска сервиса
+	Uptime    string        `json:"uptime"`     // Время работы сервиса
+	Checks    []HealthCheck `json:"checks"`     // Состояние зависимостей
+}
+
+type IdempotencyRecord struct {
+	UserID      int       // Пользователь, выполнивший запрос
+	Key         string    // Значение заголовка Idempotency-Key
+	Fingerprint string    // SHA-256 от метода, пути и тела запроса
+	Status      int       // Код сохранённого ответа (0, пока запрос выполняется)
+	ContentType string    // Content-Type сохранённого ответа
+	Body        []byte    // Тело сохранённого ответа
+	CreatedAt   time.Time // Время первого запроса
+	ExpiresAt   time.Time // Время, после которого ключ можно использовать повторно
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListQuery - параметры постраничного чтения списка заказов или списаний. Страницы выбираются
+// по ключу (время, номер заказа): следующая страница начинается сразу после Cursor.
+type ListQuery struct {
+	Limit     int
+	Cursor    *ListCursor
+	Statuses  []string  // только для заказов; пустой список - без фильтра
+	From      time.Time // включительно; нулевое значение - без ограничения
+	To        time.Time // не включительно; нулевое значение - без ограничения
+	Ascending bool      // по умолчанию новые записи идут первыми
+}
+
+// ListCursor - позиция последней записи предыдущей страницы.
+type ListCursor struct {
+	At          time.Time
+	OrderNumber int64
+}
+
+// Encode возвращает курсор в виде непрозрачной строки для параметра cursor.
+func (c ListCursor) Encode() string {
+	raw := strconv.FormatInt(c.At.UnixNano(), 10) + ":" + strconv.FormatInt(c.OrderNumber, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseListCursor разбирает строку, полученную от ListCursor.Encode.
+func ParseListCursor(value string) (ListCursor, error) {
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return ListCursor{}, fmt.Errorf("некорректный курсор")
+	}
+
+	at, number, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return ListCursor{}, fmt.Errorf("некорректный курсор")
+	}
+
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return ListCursor{}, fmt.Errorf("некорректный курсор")
+	}
+
+	orderNumber, err := strconv.ParseInt(number, 10, 64)
+	if err != nil {
+		return ListCursor{}, fmt.Errorf("некорректный курсор")
+	}
+
+	return ListCursor{At: time.Unix(0, nanos), OrderNumber: orderNumber}, nil
+}
+
+// After сообщает, что запись (at, orderNumber) идёт после курсора в заданном порядке сортировки.
+func (c ListCursor) After(at time.Time, orderNumber int64, ascending bool) bool {
+	if ascending {
+		return at.After(c.At) || at.Equal(c.At) && orderNumber > c.OrderNumber
+	}
+	return at.Before(c.At) || at.Equal(c.At) && orderNumber < c.OrderNumber
+}
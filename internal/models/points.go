@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// pointsScale - количество знаков после запятой, с которым хранятся баллы.
+const pointsScale = 2
+
+const pointsFactor = 100
+
+// Points - количество баллов лояльности в сотых долях. Арифметика над баллами выполняется
+// в целых числах, поэтому не накапливает ошибок округления. В JSON баллы передаются числом.
+type Points int64
+
+// NewPoints переводит целую и дробную части (в сотых) в Points. Части должны быть неотрицательными;
+// если результат не помещается в Points, возвращается ошибка.
+func NewPoints(units int64, cents int64) (Points, error) {
+	if units < 0 || cents < 0 || cents >= pointsFactor || units > (math.MaxInt64-cents)/pointsFactor {
+		return 0, fmt.Errorf("значение баллов вне допустимого диапазона")
+	}
+	return Points(units*pointsFactor + cents), nil
+}
+
+// ParsePoints разбирает десятичную запись вида "729.98". Допускается не более двух значащих знаков после запятой.
+func ParsePoints(value string) (Points, error) {
+	return parsePoints(value, false)
+}
+
+// ParsePointsRounded разбирает десятичную запись, округляя её до сотых (половина - от нуля).
+// Используется для сумм из внешних систем, которые могут передавать больше знаков после запятой.
+func ParsePointsRounded(value string) (Points, error) {
+	return parsePoints(value, true)
+}
+
+func parsePoints(value string, round bool) (Points, error) {
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("пустое значение баллов")
+	}
+
+	if round && strings.ContainsAny(value, "eE") {
+		return parseExponent(value)
+	}
+
+	digits := value
+	negative := false
+	switch digits[0] {
+	case '-':
+		negative = true
+		digits = digits[1:]
+	case '+':
+		digits = digits[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("некорректное значение баллов: %q", value)
+	}
+
+	roundUp := false
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > pointsScale {
+		if !round {
+			return 0, fmt.Errorf("баллы могут содержать не более %d знаков после запятой: %q", pointsScale, value)
+		}
+		if !isDigits(fracPart) {
+			return 0, fmt.Errorf("некорректное значение баллов: %q", value)
+		}
+		roundUp = fracPart[pointsScale] >= '5'
+		fracPart = fracPart[:pointsScale]
+	}
+	fracPart += strings.Repeat("0", pointsScale-len(fracPart))
+
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("некорректное значение баллов: %q", value)
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("значение баллов вне допустимого диапазона: %q", value)
+	}
+
+	cents, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректное значение баллов: %q", value)
+	}
+
+	points, err := NewPoints(units, cents)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", err, value)
+	}
+
+	if roundUp {
+		if points == math.MaxInt64 {
+			return 0, fmt.Errorf("значение баллов вне допустимого диапазона: %q", value)
+		}
+		points++
+	}
+
+	if negative {
+		points = -points
+	}
+
+	return points, nil
+}
+
+// parseExponent разбирает запись с экспонентой, например "1.5e2", с округлением до сотых.
+func parseExponent(value string) (Points, error) {
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректное значение баллов: %q", value)
+	}
+
+	f = math.Round(f * pointsFactor)
+	if math.IsNaN(f) || f >= math.MaxInt64 || f <= -math.MaxInt64 {
+		return 0, fmt.Errorf("значение баллов вне допустимого диапазона: %q", value)
+	}
+
+	return Points(f), nil
+}
+
+func isDigits(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Points) String() string {
+
+	sign := ""
+	value := int64(p)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	units := value / pointsFactor
+	cents := value % pointsFactor
+	if cents == 0 {
+		return sign + strconv.FormatInt(units, 10)
+	}
+
+	frac := strings.TrimRight(fmt.Sprintf("%02d", cents), "0")
+	return sign + strconv.FormatInt(units, 10) + "." + frac
+}
+
+// Float64 возвращает количество баллов числом с плавающей точкой, например для метрик.
+func (p Points) Float64() float64 {
+	return float64(p) / 100
+}
+
+func (p Points) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON принимает только число: строка вида "10.5" - ошибка.
+func (p *Points) UnmarshalJSON(data []byte) error {
+
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(value, `"`) {
+		return fmt.Errorf("баллы должны передаваться числом, а не строкой: %s", value)
+	}
+
+	points, err := ParsePoints(value)
+	if err != nil {
+		return err
+	}
+
+	*p = points
+	return nil
+}
+
+// Value сохраняет баллы в колонку NUMERIC в десятичном виде.
+func (p Points) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+func (p *Points) Scan(src interface{}) error {
+
+	switch value := src.(type) {
+	case nil:
+		*p = 0
+		return nil
+	case int64:
+		*p = Points(value * pointsFactor)
+		return nil
+	case float64:
+		*p = Points(math.Round(value * pointsFactor))
+		return nil
+	case string:
+		points, err := ParsePoints(value)
+		if err != nil {
+			return err
+		}
+		*p = points
+		return nil
+	case []byte:
+		points, err := ParsePoints(string(value))
+		if err != nil {
+			return err
+		}
+		*p = points
+		return nil
+	default:
+		return fmt.Errorf("неподдерживаемый тип для баллов: %T", src)
+	}
+}
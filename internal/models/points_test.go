@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePoints(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		want    Points
+		wantErr bool
+	}{
+		{value: "729.98", want: 72998},
+		{value: "-729.98", want: -72998},
+		{value: "0.5", want: 50},
+		{value: "100", want: 10000},
+		{value: "92233720368547758.07", want: 9223372036854775807},
+		{value: "-92233720368547758.07", want: -9223372036854775807},
+		{value: "184467440737095517", wantErr: true},
+		{value: "92233720368547758.08", wantErr: true},
+		{value: "92233720368547759", wantErr: true},
+		{value: "-92233720368547759", wantErr: true},
+		{value: "1.234", wantErr: true},
+		{value: "1e2", wantErr: true},
+		{value: "--1", wantErr: true},
+		{value: "1.-5", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePoints(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePoints(%q) = %d, ожидалась ошибка", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePoints(%q) = %d, %v; ожидалось %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParsePointsRounded(t *testing.T) {
+
+	tests := []struct {
+		value string
+		want  Points
+	}{
+		{value: "1.234", want: 123},
+		{value: "1.235", want: 124},
+		{value: "-1.235", want: -124},
+		{value: "0.999", want: 100},
+		{value: "1.5e2", want: 15000},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePointsRounded(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePointsRounded(%q) = %d, %v; ожидалось %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestPointsUnmarshalJSON(t *testing.T) {
+
+	var request struct {
+		Sum Points `json:"sum"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"sum": 10.5}`), &request); err != nil || request.Sum != 1050 {
+		t.Errorf("число: получено %d, %v", request.Sum, err)
+	}
+
+	for _, body := range []string{`{"sum": "10.5"}`, `{"sum": 184467440737095517}`, `{"sum": 1.234}`} {
+		if err := json.Unmarshal([]byte(body), &request); err == nil {
+			t.Errorf("%s: ожидалась ошибка", body)
+		}
+	}
+}
+
+func TestAccrualSystemResponceRoundsAccrual(t *testing.T) {
+
+	var response AccrualSystemResponce
+	err := json.Unmarshal([]byte(`{"order": "12345678903", "status": "PROCESSED", "accrual": 1.234}`), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Accrual != 123 || response.Order != "12345678903" || response.Status != "PROCESSED" {
+		t.Errorf("получено %+v", response)
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval - как часто MemoryStore удаляет заполненные корзины, чтобы не расти бесконечно.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	capacity  int
+	interval  time.Duration
+	updatedAt time.Time
+}
+
+// refill пополняет корзину на момент now.
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updatedAt)
+	if elapsed > 0 {
+		b.tokens += float64(elapsed) / float64(b.interval)
+		b.updatedAt = now
+	}
+	if b.tokens > float64(b.capacity) {
+		b.tokens = float64(b.capacity)
+	}
+}
+
+// MemoryStore хранит корзины в памяти процесса. Подходит для одного экземпляра сервиса.
+type MemoryStore struct {
+	mtx       sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (ms *MemoryStore) TakeRateLimitToken(ctx context.Context, key string, capacity int, interval time.Duration, now time.Time) (allowed bool, tokens float64, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if now.Sub(ms.lastSweep) > sweepInterval {
+		ms.sweep(now)
+	}
+
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(capacity), updatedAt: now}
+		ms.buckets[key] = b
+	}
+	b.capacity = capacity
+	b.interval = interval
+	b.refill(now)
+
+	if b.tokens < 1 {
+		return false, b.tokens, nil
+	}
+
+	b.tokens--
+	return true, b.tokens, nil
+}
+
+// sweep удаляет корзины, которые уже заполнились: они ничем не отличаются от новых.
+func (ms *MemoryStore) sweep(now time.Time) {
+	for key, b := range ms.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.capacity) {
+			delete(ms.buckets, key)
+		}
+	}
+	ms.lastSweep = now
+}
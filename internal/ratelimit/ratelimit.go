@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/authutils"
+	"github.com/maryakotova/gophermart/internal/clock"
+	"github.com/maryakotova/gophermart/internal/config"
+	"go.uber.org/zap"
+)
+
+// хранилища счётчиков
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+	StoreOff      = "off"
+)
+
+// Store хранит корзины токенов. TakeRateLimitToken пополняет корзину key ёмкостью capacity
+// одним токеном за каждый interval, прошедший с прошлого обращения, и забирает один токен,
+// если он есть. Возвращает, разрешён ли запрос, и сколько токенов осталось после этого.
+type Store interface {
+	TakeRateLimitToken(ctx context.Context, key string, capacity int, interval time.Duration, now time.Time) (allowed bool, tokens float64, err error)
+}
+
+// Limit - ограничение для маршрута: Requests запросов за Period, но не более Burst подряд.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// interval - время пополнения корзины на один токен.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// defaultLimits применяются к маршрутам, для которых ограничение не задано в конфигурации.
+var defaultLimits = map[string]Limit{
+	"default":  {Requests: 300, Period: time.Minute, Burst: 100},
+	"register": {Requests: 10, Period: time.Minute, Burst: 5},
+	"login":    {Requests: 30, Period: time.Minute, Burst: 10},
+	"refresh":  {Requests: 30, Period: time.Minute, Burst: 10},
+	"orders":   {Requests: 60, Period: time.Minute, Burst: 20},
+	"withdraw": {Requests: 30, Period: time.Minute, Burst: 10},
+}
+
+// Limiter ограничивает частоту запросов к маршрутам по идентификатору пользователя,
+// а для неаутентифицированных запросов - по IP-адресу клиента.
+type Limiter struct {
+	store    Store
+	clock    clock.Clock
+	logger   *zap.Logger
+	limits   map[string]Limit
+	disabled bool
+}
+
+func NewLimiter(cfg *config.Config, logger *zap.Logger, store Store, clk clock.Clock) (*Limiter, error) {
+
+	switch cfg.RateLimitStore {
+	case "", StoreMemory, StorePostgres, StoreOff:
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище счётчиков запросов: %q", cfg.RateLimitStore)
+	}
+
+	limits := make(map[string]Limit, len(defaultLimits))
+	for route, limit := range defaultLimits {
+		limits[route] = limit
+	}
+
+	for _, rateLimit := range cfg.RateLimits {
+		period, err := time.ParseDuration(rateLimit.Period)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный период ограничения для маршрута %q: %w", rateLimit.Route, err)
+		}
+		if rateLimit.Requests <= 0 || period <= 0 {
+			return nil, fmt.Errorf("ограничение для маршрута %q должно быть положительным", rateLimit.Route)
+		}
+
+		limit := Limit{Requests: rateLimit.Requests, Period: period, Burst: rateLimit.Burst}
+		if limit.Burst <= 0 {
+			limit.Burst = limit.Requests
+		}
+		limits[rateLimit.Route] = limit
+	}
+
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
+	return &Limiter{
+		store:    store,
+		clock:    clk,
+		logger:   logger,
+		limits:   limits,
+		disabled: cfg.RateLimitStore == StoreOff,
+	}, nil
+}
+
+// Middleware ограничивает частоту запросов к маршруту route. Ответы дополняются заголовками
+// RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset, при превышении возвращается 429 с Retry-After.
+// Для ограничения по пользователю middleware подключается после проверки авторизации.
+func (l *Limiter) Middleware(route string) func(http.Handler) http.Handler {
+
+	limit, ok := l.limits[route]
+	if !ok {
+		limit = l.limits["default"]
+	}
+
+	return func(next http.Handler) http.Handler {
+		if l.disabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			key := route + ":" + clientKey(r)
+			interval := limit.interval()
+
+			allowed, tokens, err := l.store.TakeRateLimitToken(r.Context(), key, limit.Burst, interval, l.clock.Now())
+			if err != nil {
+				// недоступность хранилища счётчиков не должна останавливать сервис
+				l.logger.Error("ошибка при проверке ограничения частоты запросов", zap.String("key", key), zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reset := time.Duration((float64(limit.Burst) - tokens) * float64(interval))
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(tokens))))
+			w.Header().Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(reset), 10))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", limit.Requests, ceilSeconds(limit.Period), limit.Burst))
+
+			if !allowed {
+				retryAfter := time.Duration((1 - tokens) * float64(interval))
+				w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(retryAfter), 10))
+				http.Error(w, "слишком много запросов, повторите позже", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+
+	if identity, ok := authutils.IdentityFromContext(r.Context()); ok {
+		return "user:" + strconv.Itoa(identity.UserID)
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
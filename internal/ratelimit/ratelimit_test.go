@@ -0,0 +1,223 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/ratelimit"
+	"github.com/maryakotova/gophermart/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+// fakeClock - часы, которые идут только по команде теста.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// failingStore - хранилище счётчиков, которое всегда недоступно.
+type failingStore struct{}
+
+func (failingStore) TakeRateLimitToken(ctx context.Context, key string, capacity int, interval time.Duration, now time.Time) (bool, float64, error) {
+	return false, 0, errors.New("хранилище недоступно")
+}
+
+// testHandler возвращает обработчик маршрута login с ограничением 60 запросов в минуту и всплеском 3:
+// один токен пополняется за секунду.
+func testHandler(t *testing.T, store ratelimit.Store, storeName string) (http.Handler, *fakeClock) {
+	t.Helper()
+
+	cfg := &config.Config{
+		RateLimitStore: storeName,
+		RateLimits:     []config.RateLimit{{Route: "login", Requests: 60, Period: "1m", Burst: 3}},
+	}
+	clk := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	limiter, err := ratelimit.NewLimiter(cfg, zap.NewNop(), store, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return limiter.Middleware("login")(next), clk
+}
+
+func request(handler http.Handler, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/user/login", nil)
+	req.RemoteAddr = ip + ":12345"
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectResponse(t *testing.T, rec *httptest.ResponseRecorder, status int, headers map[string]string) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("код %d, ожидался %d", rec.Code, status)
+	}
+	for name, want := range headers {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("заголовок %s = %q, ожидалось %q", name, got, want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+
+	handler, clk := testHandler(t, ratelimit.NewMemoryStore(), ratelimit.StoreMemory)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		expectResponse(t, request(handler, "192.0.2.1"), http.StatusOK, map[string]string{
+			"RateLimit-Limit":     "3",
+			"RateLimit-Remaining": fmt.Sprint(remaining),
+			"RateLimit-Reset":     fmt.Sprint(3 - remaining),
+			"RateLimit-Policy":    "60;w=60;burst=3",
+		})
+	}
+
+	expectResponse(t, request(handler, "192.0.2.1"), http.StatusTooManyRequests, map[string]string{
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "3",
+		"Retry-After":         "1",
+	})
+
+	// за полсекунды накапливается только половина токена
+	clk.Advance(500 * time.Millisecond)
+	expectResponse(t, request(handler, "192.0.2.1"), http.StatusTooManyRequests, map[string]string{
+		"Retry-After": "1",
+	})
+
+	clk.Advance(500 * time.Millisecond)
+	expectResponse(t, request(handler, "192.0.2.1"), http.StatusOK, map[string]string{
+		"RateLimit-Remaining": "0",
+	})
+
+	// у другого клиента своя корзина
+	expectResponse(t, request(handler, "192.0.2.2"), http.StatusOK, map[string]string{
+		"RateLimit-Remaining": "2",
+	})
+
+	// корзина пополняется не выше ёмкости
+	clk.Advance(time.Hour)
+	expectResponse(t, request(handler, "192.0.2.1"), http.StatusOK, map[string]string{
+		"RateLimit-Remaining": "2",
+	})
+}
+
+func TestStoreFailureAllowsRequest(t *testing.T) {
+
+	handler, _ := testHandler(t, failingStore{}, ratelimit.StorePostgres)
+
+	rec := request(handler, "192.0.2.1")
+	expectResponse(t, rec, http.StatusOK, nil)
+	if rec.Header().Get("RateLimit-Limit") != "" {
+		t.Error("заголовки ограничения переданы без обращения к хранилищу")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+
+	handler, _ := testHandler(t, ratelimit.NewMemoryStore(), ratelimit.StoreOff)
+
+	for i := 0; i < 10; i++ {
+		expectResponse(t, request(handler, "192.0.2.1"), http.StatusOK, map[string]string{"RateLimit-Limit": ""})
+	}
+}
+
+func TestNewLimiterValidation(t *testing.T) {
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{name: "неизвестное хранилище", cfg: config.Config{RateLimitStore: "redis"}},
+		{name: "некорректный период", cfg: config.Config{RateLimits: []config.RateLimit{{Route: "login", Requests: 1, Period: "минута"}}}},
+		{name: "нулевое количество запросов", cfg: config.Config{RateLimits: []config.RateLimit{{Route: "login", Period: "1m"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ratelimit.NewLimiter(&tt.cfg, zap.NewNop(), ratelimit.NewMemoryStore(), nil); err == nil {
+				t.Error("ожидалась ошибка конфигурации")
+			}
+		})
+	}
+}
+
+// TestPostgresStore проверяет, что параллельные запросы не забирают один токен дважды.
+// Запускается, если задан DATABASE_URI.
+func TestPostgresStore(t *testing.T) {
+
+	uri := os.Getenv("DATABASE_URI")
+	if uri == "" {
+		t.Skip("DATABASE_URI не задан")
+	}
+
+	store, err := postgres.NewPostgresStorage(&config.Config{DatabaseURI: uri}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Bootstrap(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	const capacity = 5
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	allowed := 0
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _, err := store.TakeRateLimitToken(ctx, key, capacity, time.Hour, time.Now())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mtx.Lock()
+				allowed++
+				mtx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != capacity {
+		t.Errorf("разрешено %d запросов, ожидалось %d", allowed, capacity)
+	}
+
+	ok, tokens, err := store.TakeRateLimitToken(ctx, key, capacity, time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || tokens >= 1 {
+		t.Errorf("из пустой корзины выдан токен: allowed=%v, tokens=%v", ok, tokens)
+	}
+}
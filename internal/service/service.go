@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/maryakotova/gophermart/internal/accrualservice"
+	"github.com/maryakotova/gophermart/internal/authutils"
 	"github.com/maryakotova/gophermart/internal/constants"
 	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/metrics"
 	"github.com/maryakotova/gophermart/internal/models"
 	"github.com/maryakotova/gophermart/internal/storage"
 	"github.com/maryakotova/gophermart/internal/utils"
@@ -21,16 +24,16 @@ import (
 // }
 
 type Service struct {
-	storage storage.Storage
-	logger  *zap.Logger
-	accrual *accrualservice.AccrualService
+	storage   storage.Storage
+	logger    *zap.Logger
+	passwords *utils.Passwords
 }
 
-func NewService(storage *storage.Storage, logger *zap.Logger, accrual *accrualservice.AccrualService) *Service {
+func NewService(storage *storage.Storage, logger *zap.Logger, passwords *utils.Passwords) *Service {
 	return &Service{
-		storage: *storage,
-		logger:  logger,
-		accrual: accrual,
+		storage:   *storage,
+		logger:    logger,
+		passwords: passwords,
 	}
 }
 
@@ -45,7 +48,7 @@ func (s *Service) CreateUser(ctx context.Context, login string, password string)
 		return
 	}
 
-	hashedPassword, err := utils.HashPassword(password)
+	hashedPassword, err := s.passwords.Hash(password)
 	if err != nil {
 		return
 	}
@@ -58,26 +61,37 @@ func (s *Service) CreateUser(ctx context.Context, login string, password string)
 	return
 }
 
+// CheckLoginData проверяет пароль пользователя. Если пароль захеширован устаревшим алгоритмом
+// или с другими параметрами, хеш пересчитывается по текущей конфигурации.
 func (s *Service) CheckLoginData(ctx context.Context, login string, password string) (userID int, err error) {
 
-	hashedPassword, err := utils.HashPassword(password)
+	userID, dbPassword, err := s.storage.GetUserAuthData(ctx, login)
 	if err != nil {
-		return
+		return -1, err
 	}
 
-	userID, dbPassword, err := s.storage.GetUserAuthData(ctx, login)
+	if userID == -1 {
+		return -1, customerrors.ErrInvalidCredentials
+	}
+
+	ok, needsRehash, err := s.passwords.Verify(password, dbPassword)
 	if err != nil {
-		return
+		return -1, err
 	}
 
-	if dbPassword != hashedPassword || userID == 0 {
-		err = fmt.Errorf("неверная пара логин/пароль")
-		return
+	if !ok {
+		return -1, customerrors.ErrInvalidCredentials
 	}
 
-	return
+	if needsRehash {
+		s.rehashPassword(ctx, userID, password)
+	}
+
+	return userID, nil
 }
 
+// LoadOrderNumber сохраняет заказ в статусе NEW и ставит его в очередь на расчёт начислений.
+// Обращение к системе расчёта выполняется воркерами асинхронно.
 func (s *Service) LoadOrderNumber(ctx context.Context, orderNumber int64, userID int) error {
 
 	err := s.checkOrderLoaded(ctx, orderNumber, userID)
@@ -85,31 +99,98 @@ func (s *Service) LoadOrderNumber(ctx context.Context, orderNumber int64, userID
 		return err
 	}
 
-	accrualResponce, err := s.accrual.GetAccrualFromService(orderNumber)
+	err = s.storage.InsertOrder(ctx, userID, orderNumber)
 	if err != nil {
 		return err
 	}
 
-	err = s.storage.InsertOrder(ctx, userID, accrualResponce)
-	if err != nil {
-		return err
+	metrics.OrdersUploaded.Inc()
+	return nil
+}
+
+// LoadOrderBatch проверяет номера заказов и сохраняет корректные одной транзакцией. Результат по каждому
+// номеру возвращается в порядке запроса; повтор номера в пакете считается повторной загрузкой своего заказа.
+func (s *Service) LoadOrderBatch(ctx context.Context, userID int, numbers []string) (response models.BatchOrdersResponce, err error) {
+
+	response.Results = make([]models.BatchOrderResultResponce, len(numbers))
+	parsed := make([]int64, len(numbers))
+	valid := make([]int64, 0, len(numbers))
+
+	for i, number := range numbers {
+		response.Results[i].OrderNumber = number
+
+		orderNumber, err := utils.CheckOrderNumber(number)
+		if err != nil {
+			response.Results[i].Result = constants.BatchInvalid
+			response.Results[i].Error = err.Error()
+			continue
+		}
+		parsed[i] = orderNumber
+		valid = append(valid, orderNumber)
 	}
 
-	if accrualResponce.Status == constants.Processed && accrualResponce.Accrual > 0 {
-		err = s.storage.IncreaseBalance(ctx, userID, accrualResponce.Accrual)
+	results := map[int64]string{}
+	if len(valid) > 0 {
+		results, err = s.storage.InsertOrders(ctx, userID, valid)
 		if err != nil {
-			return err
+			return response, err
 		}
 	}
 
-	return nil
+	seen := make(map[int64]bool, len(valid))
+	response.Summary = make(map[string]int, 4)
+
+	for i := range response.Results {
+		item := &response.Results[i]
+		if item.Result == "" {
+			item.Result = results[parsed[i]]
+			if seen[parsed[i]] {
+				item.Result = constants.BatchDuplicateOwn
+			}
+			seen[parsed[i]] = true
+		}
+
+		switch item.Result {
+		case constants.BatchAccepted:
+			item.Status = http.StatusAccepted
+		case constants.BatchDuplicateOwn:
+			item.Status = http.StatusOK
+			item.Error = customerrors.ErrOrderLoadedByUser.Error()
+		case constants.BatchDuplicateOther:
+			item.Status = http.StatusConflict
+			item.Error = customerrors.ErrOrderLoadedByAnotherUser.Error()
+		default:
+			item.Status = http.StatusUnprocessableEntity
+		}
+		response.Summary[item.Result]++
+	}
+
+	if accepted := response.Summary[constants.BatchAccepted]; accepted > 0 {
+		metrics.OrdersUploaded.Add(float64(accepted))
+	}
+
+	return response, nil
 }
 
-func (s *Service) GetOrders(ctx context.Context, userID int) (orders []models.OrderListResponce, err error) {
+// GetOrders возвращает страницу заказов пользователя и курсор следующей страницы
+// (пустой, если страница последняя).
+func (s *Service) GetOrders(ctx context.Context, userID int, query models.ListQuery) (orders []models.OrderListResponce, next string, err error) {
 
-	bdOrders, err := s.storage.GetOrdersForUser(ctx, userID)
+	limit := query.Limit
+	query.Limit++ // лишняя запись показывает, что есть следующая страница
+
+	bdOrders, err := s.storage.GetOrdersForUser(ctx, userID, query)
 	if err != nil {
-		return orders, err
+		return orders, "", err
+	}
+
+	if len(bdOrders) > limit {
+		bdOrders = bdOrders[:limit]
+		last := bdOrders[limit-1]
+		next, err = nextCursor(last.UploadedAt, last.OrderNumber)
+		if err != nil {
+			return orders, "", err
+		}
 	}
 
 	for _, order := range bdOrders {
@@ -122,59 +203,148 @@ func (s *Service) GetOrders(ctx context.Context, userID int) (orders []models.Or
 		)
 	}
 
-	return orders, nil
+	return orders, next, nil
 }
 
-func (s *Service) GetBalance(ctx context.Context, userID int) (balance models.BalanceResponce, err error) {
+// GetOrder возвращает заказ пользователя с историей смены статусов. Чужой заказ не отличается
+// от несуществующего: в обоих случаях возвращается customerrors.ErrOrderNotFound.
+func (s *Service) GetOrder(ctx context.Context, userID int, orderNumber int64) (order models.OrderResponce, err error) {
 
-	currentBalance, err := s.storage.GetCurrentBalance(ctx, userID)
+	bdOrder, history, err := s.orderWithHistory(ctx, userID, orderNumber)
 	if err != nil {
-		return
+		return order, err
 	}
 
-	WithdrawalSum, err := s.storage.GetWithdrawalSum(ctx, userID)
+	order = models.OrderResponce{
+		OrderNumber: strconv.FormatInt(bdOrder.OrderNumber, 10),
+		Status:      bdOrder.Status,
+		Accrural:    bdOrder.Accrual,
+		UploadedAt:  bdOrder.UploadedAt.Format(time.RFC3339),
+		History:     make([]models.OrderStatusEventResponce, 0, len(history)),
+	}
+
+	for _, event := range history {
+		order.History = append(order.History, models.OrderStatusEventResponce{
+			Status:     event.Status,
+			OccurredAt: event.OccurredAt.Format(time.RFC3339),
+		})
+	}
+
+	return order, nil
+}
+
+// GetOrderTimeline возвращает смены статуса заказа пользователя с ответами системы расчёта
+// и временем, проведённым заказом в каждом статусе.
+func (s *Service) GetOrderTimeline(ctx context.Context, userID int, orderNumber int64) (timeline []models.OrderTimelineEventResponce, err error) {
+
+	bdOrder, history, err := s.orderWithHistory(ctx, userID, orderNumber)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	balance.Balance = currentBalance
-	balance.Withdrawn = WithdrawalSum
+	timeline = make([]models.OrderTimelineEventResponce, 0, len(history))
+	previous := bdOrder.UploadedAt
+	for _, event := range history {
+		timeline = append(timeline, models.OrderTimelineEventResponce{
+			Status:          event.Status,
+			OccurredAt:      event.OccurredAt.Format(time.RFC3339),
+			SinceUpload:     event.OccurredAt.Sub(bdOrder.UploadedAt).Seconds(),
+			SincePrevious:   event.OccurredAt.Sub(previous).Seconds(),
+			Accrual:         event.Accrual,
+			AccrualResponse: event.RawResponse,
+		})
+		previous = event.OccurredAt
+	}
 
-	return balance, nil
+	return timeline, nil
 }
 
-func (s *Service) WithdrawalRequest(ctx context.Context, userID int, orderNumber int64, sum float64) (err error) {
+// GetOrderLatencyStats возвращает по дням статистику времени обработки заказов пользователя
+// (всех пользователей, если userID равен 0) за период [from, to).
+func (s *Service) GetOrderLatencyStats(ctx context.Context, userID int, from time.Time, to time.Time) (stats []models.OrderLatencyStatsResponce, err error) {
+
+	bdStats, err := s.storage.GetOrderLatencyStats(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats = make([]models.OrderLatencyStatsResponce, 0, len(bdStats))
+	for _, day := range bdStats {
+		stats = append(stats, models.OrderLatencyStatsResponce{
+			Day:    day.Day.Format(time.DateOnly),
+			Orders: day.Orders,
+			Avg:    day.Avg.Seconds(),
+			P50:    day.P50.Seconds(),
+			P95:    day.P95.Seconds(),
+			Max:    day.Max.Seconds(),
+		})
+	}
+
+	return stats, nil
+}
+
+// orderWithHistory возвращает заказ пользователя и его историю. Для чужого заказа возвращает customerrors.ErrOrderNotFound.
+func (s *Service) orderWithHistory(ctx context.Context, userID int, orderNumber int64) (order models.Order, history []models.OrderStatusEvent, err error) {
+
+	order, err = s.storage.GetOrder(ctx, orderNumber)
+	if err != nil {
+		return order, nil, err
+	}
+
+	if order.UserID != userID {
+		return models.Order{}, nil, customerrors.ErrOrderNotFound
+	}
+
+	history, err = s.storage.GetOrderHistory(ctx, orderNumber)
+	return order, history, err
+}
+
+func (s *Service) GetBalance(ctx context.Context, userID int) (balance models.BalanceResponce, err error) {
 
 	currentBalance, err := s.storage.GetCurrentBalance(ctx, userID)
 	if err != nil {
 		return
 	}
 
-	if currentBalance < sum {
-		err = customerrors.ErrLowBalance
+	WithdrawalSum, err := s.storage.GetWithdrawalSum(ctx, userID)
+	if err != nil {
 		return
 	}
 
-	newBalance := currentBalance - sum
+	balance.Balance = currentBalance
+	balance.Withdrawn = WithdrawalSum
 
-	err = s.storage.UpdateBalance(ctx, userID, newBalance)
-	if err != nil {
-		return err
-	}
+	return balance, nil
+}
 
-	err = s.storage.InsertWithdrawal(ctx, userID, orderNumber, sum)
+func (s *Service) WithdrawalRequest(ctx context.Context, userID int, orderNumber int64, sum models.Points) (err error) {
+	err = s.storage.Withdraw(ctx, userID, orderNumber, sum)
 	if err != nil {
 		return err
 	}
 
+	metrics.PointsWithdrawn.Add(sum.Float64())
 	return nil
 }
 
-func (s *Service) GetWithdraws(ctx context.Context, userID int) (withdrawals []models.WithdrawalsResponce, err error) {
+// GetWithdraws возвращает страницу списаний пользователя и курсор следующей страницы.
+func (s *Service) GetWithdraws(ctx context.Context, userID int, query models.ListQuery) (withdrawals []models.WithdrawalsResponce, next string, err error) {
+
+	limit := query.Limit
+	query.Limit++
 
-	bdWithdrawals, err := s.storage.GetWithdrawalsForUser(ctx, userID)
+	bdWithdrawals, err := s.storage.GetWithdrawalsForUser(ctx, userID, query)
 	if err != nil {
-		return withdrawals, err
+		return withdrawals, "", err
+	}
+
+	if len(bdWithdrawals) > limit {
+		bdWithdrawals = bdWithdrawals[:limit]
+		last := bdWithdrawals[limit-1]
+		next, err = nextCursor(last.ProcessedAt, last.OrderNumber)
+		if err != nil {
+			return withdrawals, "", err
+		}
 	}
 
 	for _, withdrawal := range bdWithdrawals {
@@ -185,7 +355,98 @@ func (s *Service) GetWithdraws(ctx context.Context, userID int) (withdrawals []m
 		})
 	}
 
-	return withdrawals, nil
+	return withdrawals, next, nil
+}
+
+func nextCursor(at time.Time, orderNumber string) (string, error) {
+	number, err := strconv.ParseInt(orderNumber, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("некорректный номер заказа %q: %w", orderNumber, err)
+	}
+	return models.ListCursor{At: at, OrderNumber: number}.Encode(), nil
+}
+
+// StartSession открывает сессию пользователя и возвращает её идентификатор и токен обновления.
+func (s *Service) StartSession(ctx context.Context, userID int, userAgent string, ip string, ttl time.Duration) (sessionID int64, refreshToken string, err error) {
+
+	refreshToken, refreshHash, err := authutils.NewRefreshToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	now := time.Now()
+	sessionID, err = s.storage.CreateSession(ctx, models.Session{
+		UserID:      userID,
+		RefreshHash: refreshHash,
+		UserAgent:   userAgent,
+		IP:          ip,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	return sessionID, refreshToken, nil
+}
+
+// RefreshSession обменивает токен обновления на новый. Предъявленный токен становится недействительным,
+// поэтому повторное использование украденного токена будет отклонено.
+func (s *Service) RefreshSession(ctx context.Context, refreshToken string, ttl time.Duration) (session models.Session, newRefreshToken string, err error) {
+
+	newRefreshToken, newHash, err := authutils.NewRefreshToken()
+	if err != nil {
+		return session, "", err
+	}
+
+	session, err = s.storage.RotateSession(ctx, authutils.HashRefreshToken(refreshToken), newHash, time.Now().Add(ttl))
+	if err != nil {
+		return session, "", err
+	}
+
+	return session, newRefreshToken, nil
+}
+
+func (s *Service) Logout(ctx context.Context, userID int, sessionID int64) error {
+	return s.storage.RevokeSession(ctx, userID, sessionID)
+}
+
+func (s *Service) LogoutEverywhere(ctx context.Context, userID int) error {
+	return s.storage.RevokeUserSessions(ctx, userID)
+}
+
+func (s *Service) GetSessions(ctx context.Context, userID int, currentSessionID int64) (sessions []models.SessionResponce, err error) {
+
+	dbSessions, err := s.storage.GetUserSessions(ctx, userID)
+	if err != nil {
+		return sessions, err
+	}
+
+	for _, session := range dbSessions {
+		sessions = append(sessions, models.SessionResponce{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	return sessions, nil
+}
+
+// rehashPassword обновляет хеш пароля. Ошибка не прерывает вход: хеш будет пересчитан при следующем входе.
+func (s *Service) rehashPassword(ctx context.Context, userID int, password string) {
+
+	hashedPassword, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.storage.UpdatePasswordHash(ctx, userID, hashedPassword)
+	}
+
+	if err != nil {
+		s.logger.Warn("не удалось обновить хеш пароля", zap.Int("userID", userID), zap.Error(err))
+	}
 }
 
 func (s *Service) checkUserExists(ctx context.Context, login string) (exists bool, err error) {
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/metrics"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// instrumentedStorage учитывает длительность и ошибки каждого метода хранилища в метриках.
+type instrumentedStorage struct {
+	storage Storage
+}
+
+// NewInstrumentedStorage оборачивает хранилище сбором метрик.
+func NewInstrumentedStorage(storage Storage) Storage {
+	return &instrumentedStorage{storage: storage}
+}
+
+func (s *instrumentedStorage) GetUserID(ctx context.Context, userName string) (userID int, err error) {
+	defer metrics.ObserveStorage("GetUserID", time.Now(), &err)
+	userID, err = s.storage.GetUserID(ctx, userName)
+	return
+}
+
+func (s *instrumentedStorage) CreateUser(ctx context.Context, login string, hashedPassword string) (userID int, err error) {
+	defer metrics.ObserveStorage("CreateUser", time.Now(), &err)
+	userID, err = s.storage.CreateUser(ctx, login, hashedPassword)
+	return
+}
+
+func (s *instrumentedStorage) GetUserAuthData(ctx context.Context, login string) (userID int, hashedPassword string, err error) {
+	defer metrics.ObserveStorage("GetUserAuthData", time.Now(), &err)
+	userID, hashedPassword, err = s.storage.GetUserAuthData(ctx, login)
+	return
+}
+
+func (s *instrumentedStorage) UpdatePasswordHash(ctx context.Context, userID int, hashedPassword string) (err error) {
+	defer metrics.ObserveStorage("UpdatePasswordHash", time.Now(), &err)
+	return s.storage.UpdatePasswordHash(ctx, userID, hashedPassword)
+}
+
+func (s *instrumentedStorage) GetUserByOrderNum(ctx context.Context, orderNumber int64) (userID int, err error) {
+	defer metrics.ObserveStorage("GetUserByOrderNum", time.Now(), &err)
+	userID, err = s.storage.GetUserByOrderNum(ctx, orderNumber)
+	return
+}
+
+func (s *instrumentedStorage) InsertOrder(ctx context.Context, userID int, orderNumber int64) (err error) {
+	defer metrics.ObserveStorage("InsertOrder", time.Now(), &err)
+	return s.storage.InsertOrder(ctx, userID, orderNumber)
+}
+
+func (s *instrumentedStorage) InsertOrders(ctx context.Context, userID int, orderNumbers []int64) (results map[int64]string, err error) {
+	defer metrics.ObserveStorage("InsertOrders", time.Now(), &err)
+	results, err = s.storage.InsertOrders(ctx, userID, orderNumbers)
+	return
+}
+
+func (s *instrumentedStorage) UpdateOrder(ctx context.Context, accrualResponce models.AccrualSystemResponce) (err error) {
+	defer metrics.ObserveStorage("UpdateOrder", time.Now(), &err)
+	return s.storage.UpdateOrder(ctx, accrualResponce)
+}
+
+func (s *instrumentedStorage) DequeueOrders(ctx context.Context, limit int, lease time.Duration) (orders []models.OrderToProcess, err error) {
+	defer metrics.ObserveStorage("DequeueOrders", time.Now(), &err)
+	orders, err = s.storage.DequeueOrders(ctx, limit, lease)
+	return
+}
+
+func (s *instrumentedStorage) CountQueuedOrders(ctx context.Context) (count int, err error) {
+	defer metrics.ObserveStorage("CountQueuedOrders", time.Now(), &err)
+	count, err = s.storage.CountQueuedOrders(ctx)
+	return
+}
+
+func (s *instrumentedStorage) GetOrder(ctx context.Context, orderNumber int64) (order models.Order, err error) {
+	defer metrics.ObserveStorage("GetOrder", time.Now(), &err)
+	order, err = s.storage.GetOrder(ctx, orderNumber)
+	return
+}
+
+func (s *instrumentedStorage) GetOrderHistory(ctx context.Context, orderNumber int64) (events []models.OrderStatusEvent, err error) {
+	defer metrics.ObserveStorage("GetOrderHistory", time.Now(), &err)
+	events, err = s.storage.GetOrderHistory(ctx, orderNumber)
+	return
+}
+
+func (s *instrumentedStorage) GetOrderLatencyStats(ctx context.Context, userID int, from time.Time, to time.Time) (stats []models.OrderLatencyStats, err error) {
+	defer metrics.ObserveStorage("GetOrderLatencyStats", time.Now(), &err)
+	stats, err = s.storage.GetOrderLatencyStats(ctx, userID, from, to)
+	return
+}
+
+func (s *instrumentedStorage) GetOrdersForUser(ctx context.Context, userID int, query models.ListQuery) (orders []models.OrderList, err error) {
+	defer metrics.ObserveStorage("GetOrdersForUser", time.Now(), &err)
+	orders, err = s.storage.GetOrdersForUser(ctx, userID, query)
+	return
+}
+
+func (s *instrumentedStorage) GetCurrentBalance(ctx context.Context, userID int) (balance models.Points, err error) {
+	defer metrics.ObserveStorage("GetCurrentBalance", time.Now(), &err)
+	balance, err = s.storage.GetCurrentBalance(ctx, userID)
+	return
+}
+
+func (s *instrumentedStorage) GetWithdrawalSum(ctx context.Context, userID int) (withdrawalSum models.Points, err error) {
+	defer metrics.ObserveStorage("GetWithdrawalSum", time.Now(), &err)
+	withdrawalSum, err = s.storage.GetWithdrawalSum(ctx, userID)
+	return
+}
+
+func (s *instrumentedStorage) AdjustBalance(ctx context.Context, userID int, points models.Points, comment string) (err error) {
+	defer metrics.ObserveStorage("AdjustBalance", time.Now(), &err)
+	return s.storage.AdjustBalance(ctx, userID, points, comment)
+}
+
+func (s *instrumentedStorage) GetLedgerEntries(ctx context.Context, userID int) (entries []models.LedgerEntry, err error) {
+	defer metrics.ObserveStorage("GetLedgerEntries", time.Now(), &err)
+	entries, err = s.storage.GetLedgerEntries(ctx, userID)
+	return
+}
+
+func (s *instrumentedStorage) GetLedgerBalance(ctx context.Context, userID int) (balance models.Points, err error) {
+	defer metrics.ObserveStorage("GetLedgerBalance", time.Now(), &err)
+	balance, err = s.storage.GetLedgerBalance(ctx, userID)
+	return
+}
+
+func (s *instrumentedStorage) Withdraw(ctx context.Context, userID int, orderNumber int64, points models.Points) (err error) {
+	defer metrics.ObserveStorage("Withdraw", time.Now(), &err)
+	return s.storage.Withdraw(ctx, userID, orderNumber, points)
+}
+
+func (s *instrumentedStorage) GetWithdrawalsForUser(ctx context.Context, userID int, query models.ListQuery) (withdrawals []models.Withdrawals, err error) {
+	defer metrics.ObserveStorage("GetWithdrawalsForUser", time.Now(), &err)
+	withdrawals, err = s.storage.GetWithdrawalsForUser(ctx, userID, query)
+	return
+}
+
+func (s *instrumentedStorage) CreateSession(ctx context.Context, session models.Session) (sessionID int64, err error) {
+	defer metrics.ObserveStorage("CreateSession", time.Now(), &err)
+	sessionID, err = s.storage.CreateSession(ctx, session)
+	return
+}
+
+func (s *instrumentedStorage) RotateSession(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (session models.Session, err error) {
+	defer metrics.ObserveStorage("RotateSession", time.Now(), &err)
+	session, err = s.storage.RotateSession(ctx, oldHash, newHash, expiresAt)
+	return
+}
+
+func (s *instrumentedStorage) TouchSession(ctx context.Context, sessionID int64) (active bool, err error) {
+	defer metrics.ObserveStorage("TouchSession", time.Now(), &err)
+	active, err = s.storage.TouchSession(ctx, sessionID)
+	return
+}
+
+func (s *instrumentedStorage) RevokeSession(ctx context.Context, userID int, sessionID int64) (err error) {
+	defer metrics.ObserveStorage("RevokeSession", time.Now(), &err)
+	return s.storage.RevokeSession(ctx, userID, sessionID)
+}
+
+func (s *instrumentedStorage) RevokeUserSessions(ctx context.Context, userID int) (err error) {
+	defer metrics.ObserveStorage("RevokeUserSessions", time.Now(), &err)
+	return s.storage.RevokeUserSessions(ctx, userID)
+}
+
+func (s *instrumentedStorage) GetUserSessions(ctx context.Context, userID int) (sessions []models.Session, err error) {
+	defer metrics.ObserveStorage("GetUserSessions", time.Now(), &err)
+	sessions, err = s.storage.GetUserSessions(ctx, userID)
+	return
+}
+
+func (s *instrumentedStorage) GetLoginAttempts(ctx context.Context, key string) (attempts models.LoginAttempts, err error) {
+	defer metrics.ObserveStorage("GetLoginAttempts", time.Now(), &err)
+	attempts, err = s.storage.GetLoginAttempts(ctx, key)
+	return
+}
+
+func (s *instrumentedStorage) RegisterLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (attempts models.LoginAttempts, err error) {
+	defer metrics.ObserveStorage("RegisterLoginFailure", time.Now(), &err)
+	attempts, err = s.storage.RegisterLoginFailure(ctx, key, at, window)
+	return
+}
+
+func (s *instrumentedStorage) DelayLogin(ctx context.Context, key string, until time.Time) (err error) {
+	defer metrics.ObserveStorage("DelayLogin", time.Now(), &err)
+	return s.storage.DelayLogin(ctx, key, until)
+}
+
+func (s *instrumentedStorage) LockLogin(ctx context.Context, lockout models.LoginLockout) (err error) {
+	defer metrics.ObserveStorage("LockLogin", time.Now(), &err)
+	return s.storage.LockLogin(ctx, lockout)
+}
+
+func (s *instrumentedStorage) ResetLoginAttempts(ctx context.Context, key string) (err error) {
+	defer metrics.ObserveStorage("ResetLoginAttempts", time.Now(), &err)
+	return s.storage.ResetLoginAttempts(ctx, key)
+}
+
+func (s *instrumentedStorage) UnlockLogin(ctx context.Context, key string, at time.Time) (err error) {
+	defer metrics.ObserveStorage("UnlockLogin", time.Now(), &err)
+	return s.storage.UnlockLogin(ctx, key, at)
+}
+
+func (s *instrumentedStorage) GetLoginLockouts(ctx context.Context, key string, limit int) (lockouts []models.LoginLockout, err error) {
+	defer metrics.ObserveStorage("GetLoginLockouts", time.Now(), &err)
+	lockouts, err = s.storage.GetLoginLockouts(ctx, key, limit)
+	return
+}
+
+func (s *instrumentedStorage) BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (stored models.IdempotencyRecord, created bool, err error) {
+	defer metrics.ObserveStorage("BeginIdempotentRequest", time.Now(), &err)
+	stored, created, err = s.storage.BeginIdempotentRequest(ctx, record, staleBefore)
+	return
+}
+
+func (s *instrumentedStorage) CompleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) (err error) {
+	defer metrics.ObserveStorage("CompleteIdempotentRequest", time.Now(), &err)
+	return s.storage.CompleteIdempotentRequest(ctx, record)
+}
+
+func (s *instrumentedStorage) DeleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) (err error) {
+	defer metrics.ObserveStorage("DeleteIdempotentRequest", time.Now(), &err)
+	return s.storage.DeleteIdempotentRequest(ctx, record)
+}
+
+func (s *instrumentedStorage) PurgeIdempotentRequests(ctx context.Context, before time.Time) (deleted int64, err error) {
+	defer metrics.ObserveStorage("PurgeIdempotentRequests", time.Now(), &err)
+	deleted, err = s.storage.PurgeIdempotentRequests(ctx, before)
+	return
+}
+
+func (s *instrumentedStorage) Ping(ctx context.Context) (err error) {
+	defer metrics.ObserveStorage("Ping", time.Now(), &err)
+	return s.storage.Ping(ctx)
+}
+
+func (s *instrumentedStorage) PendingMigrations(ctx context.Context) (pending int, err error) {
+	defer metrics.ObserveStorage("PendingMigrations", time.Now(), &err)
+	pending, err = s.storage.PendingMigrations(ctx)
+	return
+}
+
+func (s *instrumentedStorage) Close() error {
+	return s.storage.Close()
+}
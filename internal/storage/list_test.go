@@ -0,0 +1,128 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"go.uber.org/zap"
+)
+
+func TestListFiltersMemory(t *testing.T) {
+	testListFilters(t, memory.NewMemoryStorage(&config.Config{}, zap.NewNop()))
+}
+
+// TestListFiltersPostgres проверяет, что фильтры по периоду и курсоры не смещаются на часовой пояс
+// сервиса. Запускается, если задан DATABASE_URI.
+func TestListFiltersPostgres(t *testing.T) {
+	testListFilters(t, postgresStores(t, 1)[0])
+}
+
+func testListFilters(t *testing.T, store storage.Storage) {
+
+	// сервис работает не в UTC, а клиент передаёт границы периода в третьем часовом поясе
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	t.Cleanup(func() { time.Local = local })
+	client := time.FixedZone("UTC-3", -3*60*60)
+
+	ctx := context.Background()
+	login := fmt.Sprintf("list-test-%d", time.Now().UnixNano())
+
+	userID, err := store.CreateUser(ctx, login, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AdjustBalance(ctx, userID, models.Points(1000), "пополнение для теста"); err != nil {
+		t.Fatal(err)
+	}
+
+	// номера уникальны для каждого запуска, чтобы тест можно было повторять на одной базе
+	base := time.Now().UnixNano() / 1000 * 100
+	numbers := []int64{base + 1, base + 2, base + 3}
+
+	var split time.Time
+	for i, number := range numbers {
+		if i == 1 {
+			time.Sleep(5 * time.Millisecond)
+			split = time.Now()
+			time.Sleep(5 * time.Millisecond)
+		}
+		if err := store.InsertOrder(ctx, userID, number); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Withdraw(ctx, userID, number+50, models.Points(100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orders, err := store.GetOrdersForUser(ctx, userID, models.ListQuery{Limit: 10, From: split.In(client)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrders(t, "from", orders, numbers[2], numbers[1])
+
+	orders, err = store.GetOrdersForUser(ctx, userID, models.ListQuery{Limit: 10, To: split.In(client)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrders(t, "to", orders, numbers[0])
+
+	withdrawals, err := store.GetWithdrawalsForUser(ctx, userID, models.ListQuery{Limit: 10, From: split.UTC()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawals) != 2 {
+		t.Errorf("списаний начиная с %v: %d, ожидалось 2", split, len(withdrawals))
+	}
+
+	// курсор проходит через строку параметра так же, как в обработчиках
+	orders, err = store.GetOrdersForUser(ctx, userID, models.ListQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrders(t, "первая страница", orders, numbers[2])
+
+	number, err := strconv.ParseInt(orders[0].OrderNumber, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := models.ParseListCursor(models.ListCursor{At: orders[0].UploadedAt, OrderNumber: number}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err = store.GetOrdersForUser(ctx, userID, models.ListQuery{Limit: 1, Cursor: &cursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrders(t, "вторая страница", orders, numbers[1])
+
+	if uploaded := orders[0].UploadedAt; uploaded.Before(split) || uploaded.After(time.Now()) {
+		t.Errorf("время загрузки %v смещено относительно %v", uploaded, split)
+	}
+}
+
+func expectOrders(t *testing.T, name string, orders []models.OrderList, want ...int64) {
+	t.Helper()
+
+	got := make([]string, 0, len(orders))
+	for _, order := range orders {
+		got = append(got, order.OrderNumber)
+	}
+
+	wantStrings := make([]string, 0, len(want))
+	for _, number := range want {
+		wantStrings = append(wantStrings, strconv.FormatInt(number, 10))
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(wantStrings) {
+		t.Errorf("%s: заказы %v, ожидались %v", name, got, wantStrings)
+	}
+}
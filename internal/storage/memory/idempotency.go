@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+type idempotencyKey struct {
+	userID int
+	key    string
+}
+
+func (ms *MemoryStorage) BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (stored models.IdempotencyRecord, created bool, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	id := idempotencyKey{userID: record.UserID, key: record.Key}
+
+	if existing, ok := ms.idempotency[id]; ok {
+		expired := !existing.ExpiresAt.After(record.CreatedAt)
+		stale := existing.Status == 0 && existing.CreatedAt.Before(staleBefore)
+		if !expired && !stale {
+			return *existing, false, nil
+		}
+	}
+
+	ms.idempotency[id] = &record
+	return record, true, nil
+}
+
+func (ms *MemoryStorage) CompleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	existing, ok := ms.idempotency[idempotencyKey{userID: record.UserID, key: record.Key}]
+	if !ok || !sameIdempotentRequest(existing, record) {
+		return nil
+	}
+
+	existing.Status = record.Status
+	existing.ContentType = record.ContentType
+	existing.Body = append([]byte(nil), record.Body...)
+
+	return nil
+}
+
+func (ms *MemoryStorage) DeleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	id := idempotencyKey{userID: record.UserID, key: record.Key}
+	if existing, ok := ms.idempotency[id]; ok && sameIdempotentRequest(existing, record) {
+		delete(ms.idempotency, id)
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) PurgeIdempotentRequests(ctx context.Context, before time.Time) (deleted int64, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	for id, record := range ms.idempotency {
+		if !record.ExpiresAt.After(before) {
+			delete(ms.idempotency, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// sameIdempotentRequest сообщает, что запись создана тем же запросом, а не занята заново после истечения.
+func sameIdempotentRequest(existing *models.IdempotencyRecord, record models.IdempotencyRecord) bool {
+	return existing.Fingerprint == record.Fingerprint && existing.CreatedAt.Equal(record.CreatedAt)
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+func (ms *MemoryStorage) GetLoginAttempts(ctx context.Context, key string) (attempts models.LoginAttempts, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	if a, ok := ms.loginAttempts[key]; ok {
+		return *a, nil
+	}
+
+	return models.LoginAttempts{Key: key}, nil
+}
+
+func (ms *MemoryStorage) RegisterLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (attempts models.LoginAttempts, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	a, ok := ms.loginAttempts[key]
+	if !ok {
+		a = &models.LoginAttempts{Key: key}
+		ms.loginAttempts[key] = a
+	}
+
+	if a.LastFailureAt.Before(at.Add(-window)) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = at
+
+	return *a, nil
+}
+
+func (ms *MemoryStorage) DelayLogin(ctx context.Context, key string, until time.Time) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if a, ok := ms.loginAttempts[key]; ok {
+		a.LockedUntil = until
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) LockLogin(ctx context.Context, lockout models.LoginLockout) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if a, ok := ms.loginAttempts[lockout.Key]; ok {
+		a.LockedUntil = lockout.LockedUntil
+	}
+
+	lockout.ID = int64(len(ms.loginLockouts) + 1)
+	ms.loginLockouts = append(ms.loginLockouts, lockout)
+
+	return nil
+}
+
+func (ms *MemoryStorage) ResetLoginAttempts(ctx context.Context, key string) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	delete(ms.loginAttempts, key)
+
+	return nil
+}
+
+func (ms *MemoryStorage) UnlockLogin(ctx context.Context, key string, at time.Time) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	delete(ms.loginAttempts, key)
+
+	for i := range ms.loginLockouts {
+		lockout := &ms.loginLockouts[i]
+		if lockout.Key == key && lockout.UnlockedAt.IsZero() && lockout.LockedUntil.After(at) {
+			lockout.UnlockedAt = at
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) GetLoginLockouts(ctx context.Context, key string, limit int) (lockouts []models.LoginLockout, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	for i := len(ms.loginLockouts) - 1; i >= 0 && len(lockouts) < limit; i-- {
+		if key == "" || ms.loginLockouts[i].Key == key {
+			lockouts = append(lockouts, ms.loginLockouts[i])
+		}
+	}
+
+	return lockouts, nil
+}
@@ -0,0 +1,456 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/models"
+	"go.uber.org/zap"
+)
+
+type user struct {
+	id             int
+	login          string
+	hashedPassword string
+}
+
+type order struct {
+	number     int64
+	userID     int
+	status     string
+	accrual    models.Points
+	uploadedAt time.Time
+}
+
+type withdrawal struct {
+	orderNumber int64
+	userID      int
+	sum         models.Points
+	processedAt time.Time
+}
+
+type queueItem struct {
+	nextAttemptAt time.Time
+	attempts      int
+}
+
+// MemoryStorage - хранилище в памяти процесса для тестов и локальной разработки.
+// Повторяет поведение PostgresStorage, включая ошибки при дубликатах и журнал баллов.
+type MemoryStorage struct {
+	config *config.Config
+	logger *zap.Logger
+	mtx    sync.RWMutex
+
+	users       map[int]*user
+	usersByName map[string]*user
+	orders      map[int64]*order
+	history     map[int64][]models.OrderStatusEvent
+	queue       map[int64]*queueItem
+	withdrawals map[int64]*withdrawal
+	balances    map[int]models.Points
+	ledger      []models.LedgerEntry
+	lastUserID  int
+
+	sessions      map[int64]*session
+	lastSessionID int64
+
+	loginAttempts map[string]*models.LoginAttempts
+	loginLockouts []models.LoginLockout
+
+	idempotency map[idempotencyKey]*models.IdempotencyRecord
+}
+
+func NewMemoryStorage(cfg *config.Config, logger *zap.Logger) *MemoryStorage {
+	return &MemoryStorage{
+		config:      cfg,
+		logger:      logger,
+		users:       make(map[int]*user),
+		usersByName: make(map[string]*user),
+		orders:      make(map[int64]*order),
+		history:     make(map[int64][]models.OrderStatusEvent),
+		queue:       make(map[int64]*queueItem),
+		withdrawals: make(map[int64]*withdrawal),
+		balances:    make(map[int]models.Points),
+		sessions:    make(map[int64]*session),
+
+		loginAttempts: make(map[string]*models.LoginAttempts),
+
+		idempotency: make(map[idempotencyKey]*models.IdempotencyRecord),
+	}
+}
+
+// Close ничего не делает: хранилищу в памяти нечего освобождать.
+func (ms *MemoryStorage) Close() error {
+	return nil
+}
+
+func (ms *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// PendingMigrations всегда возвращает 0: хранилищу в памяти миграции не нужны.
+func (ms *MemoryStorage) PendingMigrations(ctx context.Context) (pending int, err error) {
+	return 0, nil
+}
+
+func (ms *MemoryStorage) GetUserID(ctx context.Context, userName string) (userID int, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	u, ok := ms.usersByName[userName]
+	if !ok {
+		return -1, nil
+	}
+
+	return u.id, nil
+}
+
+func (ms *MemoryStorage) CreateUser(ctx context.Context, login string, hashedPassword string) (userID int, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if _, ok := ms.usersByName[login]; ok {
+		return -1, customerrors.ErrUsernameTaken
+	}
+
+	ms.lastUserID++
+	u := &user{id: ms.lastUserID, login: login, hashedPassword: hashedPassword}
+	ms.users[u.id] = u
+	ms.usersByName[login] = u
+
+	return u.id, nil
+}
+
+func (ms *MemoryStorage) GetUserAuthData(ctx context.Context, login string) (userID int, hashedPassword string, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	u, ok := ms.usersByName[login]
+	if !ok {
+		return -1, "", nil
+	}
+
+	return u.id, u.hashedPassword, nil
+}
+
+func (ms *MemoryStorage) UpdatePasswordHash(ctx context.Context, userID int, hashedPassword string) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if u, ok := ms.users[userID]; ok {
+		u.hashedPassword = hashedPassword
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) GetUserByOrderNum(ctx context.Context, orderNumber int64) (userID int, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	o, ok := ms.orders[orderNumber]
+	if !ok {
+		return 0, nil
+	}
+
+	return o.userID, nil
+}
+
+func (ms *MemoryStorage) InsertOrder(ctx context.Context, userID int, orderNumber int64) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if o, ok := ms.orders[orderNumber]; ok {
+		if o.userID == userID {
+			return customerrors.ErrOrderLoadedByUser
+		}
+		return customerrors.ErrOrderLoadedByAnotherUser
+	}
+
+	now := time.Now()
+	ms.orders[orderNumber] = &order{
+		number:     orderNumber,
+		userID:     userID,
+		status:     constants.New,
+		uploadedAt: now,
+	}
+	ms.queue[orderNumber] = &queueItem{nextAttemptAt: now}
+	ms.history[orderNumber] = append(ms.history[orderNumber], models.OrderStatusEvent{Status: constants.New, OccurredAt: now})
+
+	return nil
+}
+
+func (ms *MemoryStorage) UpdateOrder(ctx context.Context, accrualResponce models.AccrualSystemResponce) error {
+
+	orderNumber, err := strconv.ParseInt(accrualResponce.Order, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	o, ok := ms.orders[orderNumber]
+	if !ok || isFinalStatus(o.status) {
+		return nil
+	}
+
+	if o.status != accrualResponce.Status {
+		ms.history[orderNumber] = append(ms.history[orderNumber], models.OrderStatusEvent{
+			Status:      accrualResponce.Status,
+			OccurredAt:  time.Now(),
+			Accrual:     accrualResponce.Accrual,
+			RawResponse: accrualResponce.Raw,
+		})
+	}
+
+	o.status = accrualResponce.Status
+	o.accrual = accrualResponce.Accrual
+
+	if accrualResponce.Status == constants.Processed && accrualResponce.Accrual > 0 {
+		ms.postLedgerTransaction(constants.LedgerAccrual, o.userID, orderNumber, accrualResponce.Accrual, "")
+	}
+
+	if isFinalStatus(accrualResponce.Status) {
+		delete(ms.queue, orderNumber)
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) DequeueOrders(ctx context.Context, limit int, lease time.Duration) (orders []models.OrderToProcess, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	now := time.Now()
+
+	due := make([]int64, 0)
+	for orderNumber, item := range ms.queue {
+		if !item.nextAttemptAt.After(now) {
+			due = append(due, orderNumber)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return ms.queue[due[i]].nextAttemptAt.Before(ms.queue[due[j]].nextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, orderNumber := range due {
+		item := ms.queue[orderNumber]
+		item.nextAttemptAt = now.Add(lease)
+		item.attempts++
+
+		o := ms.orders[orderNumber]
+		orders = append(orders, models.OrderToProcess{
+			OrderNumber: orderNumber,
+			Status:      o.status,
+			Accrual:     o.accrual,
+		})
+	}
+
+	return orders, nil
+}
+
+func (ms *MemoryStorage) CountQueuedOrders(ctx context.Context) (count int, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	return len(ms.queue), nil
+}
+
+func (ms *MemoryStorage) GetOrdersForUser(ctx context.Context, userID int, query models.ListQuery) (orders []models.OrderList, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	var selected []*order
+	for _, o := range ms.orders {
+		if o.userID != userID || !matchesList(query, o.uploadedAt, o.number) {
+			continue
+		}
+		if len(query.Statuses) > 0 && !containsString(query.Statuses, o.status) {
+			continue
+		}
+		selected = append(selected, o)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return listLess(query, selected[i].uploadedAt, selected[i].number, selected[j].uploadedAt, selected[j].number)
+	})
+
+	for _, o := range selected {
+		if len(orders) == query.Limit {
+			break
+		}
+		orders = append(orders, models.OrderList{
+			OrderNumber: strconv.FormatInt(o.number, 10),
+			Status:      o.status,
+			Accrual:     o.accrual,
+			UploadedAt:  o.uploadedAt,
+		})
+	}
+
+	return orders, nil
+}
+
+func (ms *MemoryStorage) GetCurrentBalance(ctx context.Context, userID int) (balance models.Points, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	return ms.balances[userID], nil
+}
+
+func (ms *MemoryStorage) GetWithdrawalSum(ctx context.Context, userID int) (withdrawalSum models.Points, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	for _, entry := range ms.ledger {
+		if entry.UserID == userID && entry.Kind == constants.LedgerWithdrawal {
+			withdrawalSum -= entry.Amount
+		}
+	}
+
+	return withdrawalSum, nil
+}
+
+func (ms *MemoryStorage) AdjustBalance(ctx context.Context, userID int, points models.Points, comment string) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	ms.postLedgerTransaction(constants.LedgerAdjustment, userID, 0, points, comment)
+
+	return nil
+}
+
+func (ms *MemoryStorage) GetLedgerEntries(ctx context.Context, userID int) (entries []models.LedgerEntry, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	for _, entry := range ms.ledger {
+		if entry.UserID == userID {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (ms *MemoryStorage) GetLedgerBalance(ctx context.Context, userID int) (balance models.Points, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	for _, entry := range ms.ledger {
+		if entry.UserID == userID {
+			balance += entry.Amount
+		}
+	}
+
+	return balance, nil
+}
+
+func (ms *MemoryStorage) Withdraw(ctx context.Context, userID int, orderNumber int64, points models.Points) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if ms.balances[userID] < points {
+		return customerrors.ErrLowBalance
+	}
+
+	if _, ok := ms.withdrawals[orderNumber]; ok {
+		return customerrors.ErrWithdrawalExists
+	}
+
+	ms.withdrawals[orderNumber] = &withdrawal{
+		orderNumber: orderNumber,
+		userID:      userID,
+		sum:         points,
+		processedAt: time.Now(),
+	}
+	ms.postLedgerTransaction(constants.LedgerWithdrawal, userID, orderNumber, -points, "")
+
+	return nil
+}
+
+func (ms *MemoryStorage) GetWithdrawalsForUser(ctx context.Context, userID int, query models.ListQuery) (withdrawals []models.Withdrawals, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	var selected []*withdrawal
+	for _, w := range ms.withdrawals {
+		if w.userID != userID || !matchesList(query, w.processedAt, w.orderNumber) {
+			continue
+		}
+		selected = append(selected, w)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return listLess(query, selected[i].processedAt, selected[i].orderNumber, selected[j].processedAt, selected[j].orderNumber)
+	})
+
+	for _, w := range selected {
+		if len(withdrawals) == query.Limit {
+			break
+		}
+		withdrawals = append(withdrawals, models.Withdrawals{
+			OrderNumber: strconv.FormatInt(w.orderNumber, 10),
+			Sum:         w.sum,
+			ProcessedAt: w.processedAt,
+		})
+	}
+
+	return withdrawals, nil
+}
+
+// matchesList проверяет, что запись попадает в период и идёт после курсора запроса.
+func matchesList(query models.ListQuery, at time.Time, orderNumber int64) bool {
+	if !query.From.IsZero() && at.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !at.Before(query.To) {
+		return false
+	}
+	return query.Cursor == nil || query.Cursor.After(at, orderNumber, query.Ascending)
+}
+
+// listLess задаёт порядок записей в постраничной выборке: по времени, затем по номеру заказа.
+func listLess(query models.ListQuery, atI time.Time, numberI int64, atJ time.Time, numberJ int64) bool {
+	if query.Ascending {
+		return atI.Before(atJ) || atI.Equal(atJ) && numberI < numberJ
+	}
+	return atI.After(atJ) || atI.Equal(atJ) && numberI > numberJ
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// postLedgerTransaction записывает операцию по счёту пользователя и обновляет его остаток.
+// Вызывается под блокировкой на запись.
+func (ms *MemoryStorage) postLedgerTransaction(kind string, userID int, orderNumber int64, amount models.Points, comment string) {
+	ms.ledger = append(ms.ledger, models.LedgerEntry{
+		EntryID:     int64(len(ms.ledger) + 1),
+		UserID:      userID,
+		Kind:        kind,
+		Amount:      amount,
+		OrderNumber: orderNumber,
+		Comment:     comment,
+		CreatedAt:   time.Now(),
+	})
+	ms.balances[userID] += amount
+}
+
+func isFinalStatus(status string) bool {
+	return status == constants.Processed || status == constants.Invalid || status == constants.NotRelevant
+}
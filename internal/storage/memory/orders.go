@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+func (ms *MemoryStorage) GetOrder(ctx context.Context, orderNumber int64) (order models.Order, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	o, ok := ms.orders[orderNumber]
+	if !ok {
+		return order, customerrors.ErrOrderNotFound
+	}
+
+	return models.Order{
+		OrderNumber: o.number,
+		UserID:      o.userID,
+		Status:      o.status,
+		Accrual:     o.accrual,
+		UploadedAt:  o.uploadedAt,
+	}, nil
+}
+
+func (ms *MemoryStorage) InsertOrders(ctx context.Context, userID int, orderNumbers []int64) (results map[int64]string, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	now := time.Now()
+	results = make(map[int64]string, len(orderNumbers))
+
+	for _, orderNumber := range orderNumbers {
+		if _, ok := results[orderNumber]; ok {
+			continue
+		}
+
+		if o, ok := ms.orders[orderNumber]; ok {
+			if o.userID == userID {
+				results[orderNumber] = constants.BatchDuplicateOwn
+			} else {
+				results[orderNumber] = constants.BatchDuplicateOther
+			}
+			continue
+		}
+
+		ms.orders[orderNumber] = &order{
+			number:     orderNumber,
+			userID:     userID,
+			status:     constants.New,
+			uploadedAt: now,
+		}
+		ms.queue[orderNumber] = &queueItem{nextAttemptAt: now}
+		ms.history[orderNumber] = append(ms.history[orderNumber], models.OrderStatusEvent{Status: constants.New, OccurredAt: now})
+		results[orderNumber] = constants.BatchAccepted
+	}
+
+	return results, nil
+}
+
+func (ms *MemoryStorage) GetOrderHistory(ctx context.Context, orderNumber int64) (events []models.OrderStatusEvent, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	return append(events, ms.history[orderNumber]...), nil
+}
+
+func (ms *MemoryStorage) GetOrderLatencyStats(ctx context.Context, userID int, from time.Time, to time.Time) (stats []models.OrderLatencyStats, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	byDay := make(map[time.Time][]time.Duration)
+	for orderNumber, events := range ms.history {
+		o := ms.orders[orderNumber]
+		if userID != 0 && o.userID != userID {
+			continue
+		}
+		for _, event := range events {
+			if !isFinalStatus(event.Status) || event.OccurredAt.Before(from) || !event.OccurredAt.Before(to) {
+				continue
+			}
+			day := event.OccurredAt.UTC().Truncate(24 * time.Hour)
+			byDay[day] = append(byDay[day], event.OccurredAt.Sub(o.uploadedAt))
+		}
+	}
+
+	for day, latencies := range byDay {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		var total time.Duration
+		for _, latency := range latencies {
+			total += latency
+		}
+
+		stats = append(stats, models.OrderLatencyStats{
+			Day:    day,
+			Orders: len(latencies),
+			Avg:    total / time.Duration(len(latencies)),
+			P50:    percentile(latencies, 0.5),
+			P95:    percentile(latencies, 0.95),
+			Max:    latencies[len(latencies)-1],
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Day.Before(stats[j].Day) })
+
+	return stats, nil
+}
+
+// percentile возвращает значение по рангу (как percentile_disc в Postgres) из упорядоченного среза.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
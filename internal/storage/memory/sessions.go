@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+type session struct {
+	models.Session
+	revoked bool
+}
+
+func (s *session) active(now time.Time) bool {
+	return !s.revoked && s.ExpiresAt.After(now)
+}
+
+func (ms *MemoryStorage) CreateSession(ctx context.Context, newSession models.Session) (sessionID int64, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	ms.lastSessionID++
+	newSession.ID = ms.lastSessionID
+	newSession.LastSeenAt = newSession.CreatedAt
+	ms.sessions[newSession.ID] = &session{Session: newSession}
+
+	return newSession.ID, nil
+}
+
+func (ms *MemoryStorage) RotateSession(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (models.Session, error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	now := time.Now()
+	for _, s := range ms.sessions {
+		if s.RefreshHash == oldHash && s.active(now) {
+			s.RefreshHash = newHash
+			s.ExpiresAt = expiresAt
+			s.LastSeenAt = now
+			return s.Session, nil
+		}
+	}
+
+	return models.Session{}, customerrors.ErrSessionNotFound
+}
+
+func (ms *MemoryStorage) TouchSession(ctx context.Context, sessionID int64) (active bool, err error) {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	now := time.Now()
+	s, ok := ms.sessions[sessionID]
+	if !ok || !s.active(now) {
+		return false, nil
+	}
+	s.LastSeenAt = now
+
+	return true, nil
+}
+
+func (ms *MemoryStorage) RevokeSession(ctx context.Context, userID int, sessionID int64) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if s, ok := ms.sessions[sessionID]; ok && s.UserID == userID {
+		s.revoked = true
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) RevokeUserSessions(ctx context.Context, userID int) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	for _, s := range ms.sessions {
+		if s.UserID == userID {
+			s.revoked = true
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStorage) GetUserSessions(ctx context.Context, userID int) (sessions []models.Session, err error) {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	now := time.Now()
+	for _, s := range ms.sessions {
+		if s.UserID == userID && s.active(now) {
+			sessions = append(sessions, s.Session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
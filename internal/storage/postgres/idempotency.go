@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// BeginIdempotentRequest занимает ключ идемпотентности для выполнения запроса. Если ключ свободен, истёк
+// или занят запросом, который начался раньше staleBefore и так и не завершился, запись создаётся заново
+// и возвращается created = true. Иначе возвращается сохранённая запись.
+func (ps *PostgresStorage) BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (stored models.IdempotencyRecord, created bool, err error) {
+
+	queryBegin := `
+	INSERT INTO idempotency_keys (user_id, idem_key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, idem_key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, status = 0, content_type = '', body = NULL,
+				created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+				OR (idempotency_keys.status = 0 AND idempotency_keys.created_at < $6)
+		RETURNING user_id;
+	`
+
+	querySelect := `
+	SELECT user_id, idem_key, fingerprint, status, content_type, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idem_key = $2;
+	`
+
+	// запись может быть удалена между запросами, если исходный запрос завершился ошибкой; тогда пробуем ещё раз
+	for attempt := 0; attempt < 2; attempt++ {
+		var userID int
+		err = ps.db.QueryRowContext(ctx, queryBegin, record.UserID, record.Key, record.Fingerprint,
+			record.CreatedAt, record.ExpiresAt, staleBefore).Scan(&userID)
+		if err == nil {
+			return record, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return stored, false, err
+		}
+
+		err = ps.db.QueryRowContext(ctx, querySelect, record.UserID, record.Key).Scan(&stored.UserID, &stored.Key,
+			&stored.Fingerprint, &stored.Status, &stored.ContentType, &stored.Body, &stored.CreatedAt, &stored.ExpiresAt)
+		if err == nil {
+			return stored, false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return stored, false, err
+		}
+	}
+
+	return stored, false, fmt.Errorf("не удалось занять ключ идемпотентности %q", record.Key)
+}
+
+// CompleteIdempotentRequest сохраняет ответ на запрос, занявший ключ идемпотентности.
+func (ps *PostgresStorage) CompleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error {
+
+	query := `
+	UPDATE idempotency_keys
+		SET status = $3, content_type = $4, body = $5
+		WHERE user_id = $1 AND idem_key = $2 AND fingerprint = $6 AND created_at = $7;
+	`
+
+	_, err := ps.db.ExecContext(ctx, query, record.UserID, record.Key, record.Status, record.ContentType, record.Body,
+		record.Fingerprint, record.CreatedAt)
+
+	return err
+}
+
+// DeleteIdempotentRequest освобождает ключ, если запрос завершился ошибкой сервера, чтобы его можно было повторить.
+func (ps *PostgresStorage) DeleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error {
+
+	query := `
+	DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idem_key = $2 AND fingerprint = $3 AND created_at = $4;
+	`
+
+	_, err := ps.db.ExecContext(ctx, query, record.UserID, record.Key, record.Fingerprint, record.CreatedAt)
+
+	return err
+}
+
+// PurgeIdempotentRequests удаляет истёкшие ключи идемпотентности.
+func (ps *PostgresStorage) PurgeIdempotentRequests(ctx context.Context, before time.Time) (deleted int64, err error) {
+
+	query := `
+	DELETE FROM idempotency_keys
+		WHERE expires_at <= $1;
+	`
+
+	result, err := ps.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// Счета журнала баллов. Каждая операция записывается двумя проводками: по счёту пользователя
+// и по системному счёту, поэтому сумма проводок любой операции равна нулю.
+const (
+	accountUser        = "user"
+	accountAccrual     = "system:accrual"
+	accountWithdrawals = "system:withdrawals"
+	accountAdjustments = "system:adjustments"
+)
+
+func counterAccount(kind string) string {
+	switch kind {
+	case constants.LedgerAccrual:
+		return accountAccrual
+	case constants.LedgerWithdrawal:
+		return accountWithdrawals
+	default:
+		return accountAdjustments
+	}
+}
+
+// postLedgerTransaction записывает операцию в журнал и обновляет материализованный остаток пользователя.
+// amount положителен для зачислений и отрицателен для списаний.
+func postLedgerTransaction(ctx context.Context, tx *sql.Tx, kind string, userID int, orderNumber sql.NullInt64, amount models.Points, comment string) error {
+
+	queryTransaction := `
+	INSERT INTO ledger_transactions (kind, user_id, order_num, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING tx_id;
+	`
+
+	queryEntries := `
+	INSERT INTO ledger_entries (tx_id, account, user_id, amount)
+		VALUES ($1, $2, $3, $4), ($1, $5, NULL, $6);
+	`
+
+	queryBalance := `
+	INSERT INTO balance (user_id, sum)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id)
+		DO UPDATE SET sum = balance.sum + EXCLUDED.sum;
+	`
+
+	var txID int64
+	err := tx.QueryRowContext(ctx, queryTransaction, kind, userID, orderNumber, comment, time.Now()).Scan(&txID)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи операции в журнал: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, queryEntries, txID, accountUser, userID, amount, counterAccount(kind), -amount)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи проводок в журнал: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, queryBalance, userID, amount)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AdjustBalance записывает в журнал ручную корректировку баланса пользователя.
+func (ps *PostgresStorage) AdjustBalance(ctx context.Context, userID int, points models.Points, comment string) error {
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = postLedgerTransaction(ctx, tx, constants.LedgerAdjustment, userID, sql.NullInt64{}, points, comment)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLedgerEntries возвращает все операции по счёту пользователя в порядке их записи.
+func (ps *PostgresStorage) GetLedgerEntries(ctx context.Context, userID int) (entries []models.LedgerEntry, err error) {
+
+	query := `
+	SELECT e.entry_id, t.kind, e.amount, t.order_num, t.comment, t.created_at
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.tx_id = e.tx_id
+		WHERE e.account = $1 AND e.user_id = $2
+		ORDER BY e.entry_id;
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, accountUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.LedgerEntry
+		var orderNumber sql.NullInt64
+		err := rows.Scan(&entry.EntryID, &entry.Kind, &entry.Amount, &orderNumber, &entry.Comment, &entry.CreatedAt)
+		if err != nil {
+			err = fmt.Errorf("ошибка при считывании строки: %w", err)
+			return nil, err
+		}
+		entry.UserID = userID
+		entry.OrderNumber = orderNumber.Int64
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetCurrentBalance возвращает материализованный остаток, который обновляется вместе с каждой операцией журнала.
+func (ps *PostgresStorage) GetCurrentBalance(ctx context.Context, userID int) (balance models.Points, err error) {
+
+	query := `
+	SELECT sum
+		FROM balance
+		WHERE user_id = $1;
+	`
+	err = ps.db.QueryRowContext(ctx, query, userID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// GetWithdrawalSum возвращает сумму списаний пользователя по журналу.
+func (ps *PostgresStorage) GetWithdrawalSum(ctx context.Context, userID int) (withdrawalSum models.Points, err error) {
+
+	query := `
+	SELECT COALESCE(-SUM(e.amount), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.tx_id = e.tx_id
+		WHERE e.account = $1 AND e.user_id = $2 AND t.kind = $3;
+	`
+
+	err = ps.db.QueryRowContext(ctx, query, accountUser, userID, constants.LedgerWithdrawal).Scan(&withdrawalSum)
+	if err != nil {
+		return 0, err
+	}
+
+	return withdrawalSum, nil
+}
+
+// GetLedgerBalance пересчитывает остаток пользователя по журналу без учёта материализованного значения.
+func (ps *PostgresStorage) GetLedgerBalance(ctx context.Context, userID int) (balance models.Points, err error) {
+
+	query := `
+	SELECT COALESCE(SUM(amount), 0)
+		FROM ledger_entries
+		WHERE account = $1 AND user_id = $2;
+	`
+
+	err = ps.db.QueryRowContext(ctx, query, accountUser, userID).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// GetBalanceMismatches сверяет материализованные остатки всех пользователей с журналом
+// и возвращает пользователей, у которых они расходятся.
+func (ps *PostgresStorage) GetBalanceMismatches(ctx context.Context) (mismatches []models.BalanceMismatch, err error) {
+
+	query := `
+	WITH ledger AS (
+		SELECT user_id, SUM(amount) AS sum
+			FROM ledger_entries
+			WHERE account = $1
+			GROUP BY user_id
+	)
+	SELECT u.user_id, u.user_name, COALESCE(b.sum, 0), COALESCE(l.sum, 0)
+		FROM users u
+		LEFT JOIN balance b ON b.user_id = u.user_id
+		LEFT JOIN ledger l ON l.user_id = u.user_id
+		WHERE COALESCE(b.sum, 0) <> COALESCE(l.sum, 0)
+		ORDER BY u.user_id;
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, accountUser)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mismatch models.BalanceMismatch
+		err := rows.Scan(&mismatch.UserID, &mismatch.Login, &mismatch.Balance, &mismatch.LedgerBalance)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при считывании строки: %w", err)
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+
+	return mismatches, rows.Err()
+}
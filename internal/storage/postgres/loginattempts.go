@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+func (ps *PostgresStorage) GetLoginAttempts(ctx context.Context, key string) (attempts models.LoginAttempts, err error) {
+
+	query := `
+	SELECT failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE attempt_key = $1;
+	`
+
+	var lockedUntil sql.NullTime
+
+	err = ps.db.QueryRowContext(ctx, query, key).Scan(&attempts.Failures, &attempts.LastFailureAt, &lockedUntil)
+	attempts.Key = key
+	if errors.Is(err, sql.ErrNoRows) {
+		return attempts, nil
+	}
+	if err != nil {
+		return attempts, err
+	}
+
+	attempts.LockedUntil = lockedUntil.Time
+	return attempts, nil
+}
+
+// RegisterLoginFailure увеличивает счётчик неудачных попыток. Если с последней неудачной попытки
+// прошло больше window, счётчик начинается заново.
+func (ps *PostgresStorage) RegisterLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (attempts models.LoginAttempts, err error) {
+
+	query := `
+	INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE
+		SET failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures, last_failure_at, locked_until;
+	`
+
+	var lockedUntil sql.NullTime
+
+	err = ps.db.QueryRowContext(ctx, query, key, at, at.Add(-window)).Scan(&attempts.Failures, &attempts.LastFailureAt, &lockedUntil)
+	if err != nil {
+		return attempts, err
+	}
+
+	attempts.Key = key
+	attempts.LockedUntil = lockedUntil.Time
+	return attempts, nil
+}
+
+// DelayLogin запрещает вход до указанного времени без записи события блокировки.
+func (ps *PostgresStorage) DelayLogin(ctx context.Context, key string, until time.Time) error {
+
+	query := `
+	UPDATE login_attempts
+		SET locked_until = $2
+		WHERE attempt_key = $1;
+	`
+
+	_, err := ps.db.ExecContext(ctx, query, key, until)
+
+	return err
+}
+
+// LockLogin блокирует вход и записывает событие блокировки в журнал.
+func (ps *PostgresStorage) LockLogin(ctx context.Context, lockout models.LoginLockout) error {
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE login_attempts SET locked_until = $2 WHERE attempt_key = $1;`,
+		lockout.Key, lockout.LockedUntil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO login_lockouts (attempt_key, failures, locked_at, locked_until)
+		VALUES ($1, $2, $3, $4);
+	`
+	_, err = tx.ExecContext(ctx, query, lockout.Key, lockout.Failures, lockout.LockedAt, lockout.LockedUntil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ps *PostgresStorage) ResetLoginAttempts(ctx context.Context, key string) error {
+
+	_, err := ps.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE attempt_key = $1;`, key)
+
+	return err
+}
+
+// UnlockLogin снимает блокировку вручную: сбрасывает счётчик попыток и отмечает действующие блокировки как снятые.
+func (ps *PostgresStorage) UnlockLogin(ctx context.Context, key string, at time.Time) error {
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM login_attempts WHERE attempt_key = $1;`, key)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE login_lockouts
+		SET unlocked_at = $2
+		WHERE attempt_key = $1 AND unlocked_at IS NULL AND locked_until > $2;
+	`
+	_, err = tx.ExecContext(ctx, query, key, at)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetLoginLockouts возвращает последние события блокировки по ключу или по всем ключам, если ключ не задан.
+func (ps *PostgresStorage) GetLoginLockouts(ctx context.Context, key string, limit int) (lockouts []models.LoginLockout, err error) {
+
+	query := `
+	SELECT lockout_id, attempt_key, failures, locked_at, locked_until, unlocked_at
+		FROM login_lockouts
+		WHERE $1 = '' OR attempt_key = $1
+		ORDER BY locked_at DESC
+		LIMIT $2;
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, key, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lockout models.LoginLockout
+		var unlockedAt sql.NullTime
+		err := rows.Scan(&lockout.ID, &lockout.Key, &lockout.Failures, &lockout.LockedAt, &lockout.LockedUntil, &unlockedAt)
+		if err != nil {
+			err = fmt.Errorf("ошибка при считывании строки: %w", err)
+			return nil, err
+		}
+		lockout.UnlockedAt = unlockedAt.Time
+		lockouts = append(lockouts, lockout)
+	}
+
+	return lockouts, rows.Err()
+}
@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey - ключ advisory-блокировки, которая не даёт нескольким экземплярам
+// сервиса применять миграции одновременно.
+const migrationLockKey = 7243019581
+
+type migration struct {
+	version int64
+	name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// loadMigrations читает встроенные файлы вида 0001_name.up.sql и 0001_name.down.sql.
+func loadMigrations() ([]migration, error) {
+
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, file := range files {
+		base := strings.TrimPrefix(file, "migrations/")
+
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+			base = strings.TrimSuffix(base, ".up.sql")
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+			base = strings.TrimSuffix(base, ".down.sql")
+		default:
+			return nil, fmt.Errorf("некорректное имя файла миграции: %s", file)
+		}
+
+		versionS, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("некорректное имя файла миграции: %s", file)
+		}
+
+		version, err := strconv.ParseInt(versionS, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная версия миграции %s: %w", file, err)
+		}
+
+		content, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("для миграции %d отсутствует up-файл", m.version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// withMigrationLock выполняет fn на отдельном соединении под advisory-блокировкой.
+func (ps *PostgresStorage) withMigrationLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+
+	conn, err := ps.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1);", migrationLockKey)
+	if err != nil {
+		return fmt.Errorf("не удалось получить блокировку для миграций: %w", err)
+	}
+	defer func() {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1);", migrationLockKey)
+		if err != nil {
+			ps.logger.Error("не удалось снять блокировку миграций: " + err.Error())
+		}
+	}()
+
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);
+	`
+
+	_, err = conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+
+	query := `
+	SELECT version, applied_at
+		FROM schema_migrations;
+	`
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при считывании строки: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// MigrateUp применяет все ещё не применённые миграции. Каждая миграция выполняется в своей транзакции.
+func (ps *PostgresStorage) MigrateUp(ctx context.Context) error {
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return ps.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.version]; ok {
+				continue
+			}
+
+			err = runMigration(ctx, conn, m.up, func(tx *sql.Tx) error {
+				query := `
+				INSERT INTO schema_migrations (version, name, applied_at)
+					VALUES ($1, $2, $3);
+				`
+				_, err := tx.ExecContext(ctx, query, m.version, m.name, time.Now())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка при применении миграции %d_%s: %w", m.version, m.name, err)
+			}
+
+			ps.logger.Info(fmt.Sprintf("применена миграция %d_%s", m.version, m.name))
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown откатывает последние steps применённых миграций.
+func (ps *PostgresStorage) MigrateDown(ctx context.Context, steps int) error {
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return ps.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+
+			if m.down == "" {
+				return fmt.Errorf("миграция %d_%s не поддерживает откат", m.version, m.name)
+			}
+
+			err = runMigration(ctx, conn, m.down, func(tx *sql.Tx) error {
+				query := `
+				DELETE FROM schema_migrations
+					WHERE version = $1;
+				`
+				_, err := tx.ExecContext(ctx, query, m.version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("ошибка при откате миграции %d_%s: %w", m.version, m.name, err)
+			}
+
+			ps.logger.Info(fmt.Sprintf("откачена миграция %d_%s", m.version, m.name))
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// MigrationStatus возвращает список известных миграций с признаком применения.
+func (ps *PostgresStorage) MigrationStatus(ctx context.Context) (statuses []MigrationStatus, err error) {
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ps.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			appliedAt, ok := applied[m.version]
+			statuses = append(statuses, MigrationStatus{
+				Version:   m.version,
+				Name:      m.name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// PendingMigrations возвращает количество встроенных миграций, ещё не применённых к базе.
+// В отличие от MigrationStatus не берёт блокировку миграций, поэтому подходит для проверок готовности.
+func (ps *PostgresStorage) PendingMigrations(ctx context.Context) (pending int, err error) {
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := ps.db.QueryContext(ctx, `SELECT version FROM schema_migrations;`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return 0, fmt.Errorf("ошибка при считывании строки: %w", err)
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, m := range migrations {
+		if !applied[m.version] {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, query string, record func(tx *sql.Tx) error) error {
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,29 @@
+-- IF NOT EXISTS оставлено для баз, созданных до появления миграций
+CREATE TABLE IF NOT EXISTS users (
+	user_id SERIAL PRIMARY KEY,
+	user_name VARCHAR(50) UNIQUE NOT NULL,
+	password VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	order_num BIGINT PRIMARY KEY,
+	user_id INT NOT NULL,
+	status VARCHAR(10) NOT NULL,
+	uploaded_at TIMESTAMP NOT NULL,
+	points DOUBLE PRECISION,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS withdrawals (
+	order_num BIGINT PRIMARY KEY,
+	user_id INT NOT NULL,
+	processed_at TIMESTAMP NOT NULL,
+	points DOUBLE PRECISION,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE TABLE IF NOT EXISTS balance (
+	user_id INT PRIMARY KEY,
+	sum DOUBLE PRECISION NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
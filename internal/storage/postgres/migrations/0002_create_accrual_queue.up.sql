@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS accrual_queue (
+	order_num BIGINT PRIMARY KEY,
+	enqueued_at TIMESTAMP NOT NULL,
+	next_attempt_at TIMESTAMP NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	FOREIGN KEY (order_num) REFERENCES orders(order_num)
+);
+
+CREATE INDEX IF NOT EXISTS accrual_queue_next_attempt_idx ON accrual_queue (next_attempt_at);
+
+-- заказы, загруженные до появления очереди, ставятся в неё
+INSERT INTO accrual_queue (order_num, enqueued_at, next_attempt_at)
+	SELECT order_num, uploaded_at, NOW()
+	FROM orders
+	WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING')
+	ON CONFLICT (order_num) DO NOTHING;
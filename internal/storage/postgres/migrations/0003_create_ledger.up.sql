@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+	tx_id BIGSERIAL PRIMARY KEY,
+	kind VARCHAR(20) NOT NULL,
+	user_id INT NOT NULL,
+	order_num BIGINT,
+	comment TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ledger_transactions_order_kind_idx
+	ON ledger_transactions (order_num, kind) WHERE order_num IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	entry_id BIGSERIAL PRIMARY KEY,
+	tx_id BIGINT NOT NULL,
+	account VARCHAR(50) NOT NULL,
+	user_id INT,
+	amount DOUBLE PRECISION NOT NULL,
+	FOREIGN KEY (tx_id) REFERENCES ledger_transactions(tx_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_user_idx ON ledger_entries (user_id);
+
+CREATE OR REPLACE FUNCTION ledger_forbid_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'записи журнала баллов неизменяемы';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_transactions_immutable ON ledger_transactions;
+CREATE TRIGGER ledger_transactions_immutable BEFORE UPDATE OR DELETE ON ledger_transactions
+	FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+	FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
+
+-- остатки, накопленные до появления журнала, переносятся начальной корректировкой
+-- на сумму всех начислений, а ранее выполненные списания - отдельными операциями
+WITH legacy AS (
+	SELECT b.user_id, b.sum + COALESCE((SELECT SUM(w.points) FROM withdrawals w WHERE w.user_id = b.user_id), 0) AS opening
+		FROM balance b
+		WHERE NOT EXISTS (SELECT 1 FROM ledger_transactions t WHERE t.user_id = b.user_id)
+), txs AS (
+	INSERT INTO ledger_transactions (kind, user_id, comment, created_at)
+		SELECT 'ADJUSTMENT', user_id, 'перенос остатка', NOW() FROM legacy
+		RETURNING tx_id, user_id
+)
+INSERT INTO ledger_entries (tx_id, account, user_id, amount)
+	SELECT txs.tx_id, 'user', txs.user_id, legacy.opening FROM txs JOIN legacy USING (user_id)
+	UNION ALL
+	SELECT txs.tx_id, 'system:adjustments', NULL, -legacy.opening FROM txs JOIN legacy USING (user_id);
+
+WITH legacy AS (
+	SELECT w.order_num, w.user_id, w.points, w.processed_at
+		FROM withdrawals w
+		WHERE NOT EXISTS (SELECT 1 FROM ledger_transactions t WHERE t.kind = 'WITHDRAWAL' AND t.order_num = w.order_num)
+), txs AS (
+	INSERT INTO ledger_transactions (kind, user_id, order_num, comment, created_at)
+		SELECT 'WITHDRAWAL', user_id, order_num, 'перенос списания', processed_at FROM legacy
+		RETURNING tx_id, order_num
+)
+INSERT INTO ledger_entries (tx_id, account, user_id, amount)
+	SELECT txs.tx_id, 'user', legacy.user_id, -legacy.points FROM txs JOIN legacy USING (order_num)
+	UNION ALL
+	SELECT txs.tx_id, 'system:withdrawals', NULL, legacy.points FROM txs JOIN legacy USING (order_num);
@@ -0,0 +1,4 @@
+ALTER TABLE ledger_entries ALTER COLUMN amount TYPE DOUBLE PRECISION;
+ALTER TABLE balance ALTER COLUMN sum TYPE DOUBLE PRECISION;
+ALTER TABLE withdrawals ALTER COLUMN points TYPE DOUBLE PRECISION;
+ALTER TABLE orders ALTER COLUMN points TYPE DOUBLE PRECISION;
@@ -0,0 +1,5 @@
+-- баллы хранятся в NUMERIC, чтобы суммы не накапливали ошибок округления
+ALTER TABLE orders ALTER COLUMN points TYPE NUMERIC(20, 2);
+ALTER TABLE withdrawals ALTER COLUMN points TYPE NUMERIC(20, 2);
+ALTER TABLE balance ALTER COLUMN sum TYPE NUMERIC(20, 2);
+ALTER TABLE ledger_entries ALTER COLUMN amount TYPE NUMERIC(20, 2);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS withdrawals_user_processed_idx;
+DROP INDEX IF EXISTS orders_user_uploaded_idx;
+
+ALTER TABLE orders ALTER COLUMN status TYPE VARCHAR(10);
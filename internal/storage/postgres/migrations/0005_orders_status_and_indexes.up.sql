@@ -0,0 +1,4 @@
+ALTER TABLE orders ALTER COLUMN status TYPE VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS orders_user_uploaded_idx ON orders (user_id, uploaded_at DESC);
+CREATE INDEX IF NOT EXISTS withdrawals_user_processed_idx ON withdrawals (user_id, processed_at DESC);
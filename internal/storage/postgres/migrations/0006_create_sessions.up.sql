@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS sessions (
+	session_id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	refresh_hash VARCHAR(64) NOT NULL UNIQUE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip VARCHAR(64) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	last_seen_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id) WHERE revoked_at IS NULL;
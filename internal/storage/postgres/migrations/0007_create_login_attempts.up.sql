@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+	attempt_key TEXT PRIMARY KEY,
+	failures INT NOT NULL DEFAULT 0,
+	last_failure_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+	lockout_id BIGSERIAL PRIMARY KEY,
+	attempt_key TEXT NOT NULL,
+	failures INT NOT NULL,
+	locked_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP NOT NULL,
+	unlocked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS login_lockouts_key_idx ON login_lockouts (attempt_key, locked_at DESC);
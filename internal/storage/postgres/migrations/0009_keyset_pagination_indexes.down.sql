@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS withdrawals_user_processed_num_idx;
+DROP INDEX IF EXISTS orders_user_status_uploaded_num_idx;
+DROP INDEX IF EXISTS orders_user_uploaded_num_idx;
+
+CREATE INDEX IF NOT EXISTS orders_user_uploaded_idx ON orders (user_id, uploaded_at DESC);
+CREATE INDEX IF NOT EXISTS withdrawals_user_processed_idx ON withdrawals (user_id, processed_at DESC);
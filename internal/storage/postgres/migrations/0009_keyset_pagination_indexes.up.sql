@@ -0,0 +1,7 @@
+-- номер заказа в индексах - второй ключ сортировки для постраничной выборки при одинаковом времени
+DROP INDEX IF EXISTS orders_user_uploaded_idx;
+DROP INDEX IF EXISTS withdrawals_user_processed_idx;
+
+CREATE INDEX IF NOT EXISTS orders_user_uploaded_num_idx ON orders (user_id, uploaded_at, order_num);
+CREATE INDEX IF NOT EXISTS orders_user_status_uploaded_num_idx ON orders (user_id, status, uploaded_at, order_num);
+CREATE INDEX IF NOT EXISTS withdrawals_user_processed_num_idx ON withdrawals (user_id, processed_at, order_num);
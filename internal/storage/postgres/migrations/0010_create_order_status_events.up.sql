@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS order_status_events (
+	event_id BIGSERIAL PRIMARY KEY,
+	order_num BIGINT NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	occurred_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (order_num) REFERENCES orders(order_num)
+);
+
+CREATE INDEX IF NOT EXISTS order_status_events_order_idx ON order_status_events (order_num, occurred_at, event_id);
+
+-- для ранее загруженных заказов история восстанавливается по времени загрузки
+-- и, для обработанных заказов, по времени начисления баллов
+INSERT INTO order_status_events (order_num, status, occurred_at)
+	SELECT order_num, 'NEW', uploaded_at
+	FROM orders;
+
+INSERT INTO order_status_events (order_num, status, occurred_at)
+	SELECT o.order_num, o.status, COALESCE(t.created_at, o.uploaded_at)
+	FROM orders o
+	LEFT JOIN ledger_transactions t ON t.order_num = o.order_num AND t.kind = 'ACCRUAL'
+	WHERE o.status <> 'NEW';
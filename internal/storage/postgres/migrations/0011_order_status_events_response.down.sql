@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS order_status_events_final_idx;
+
+ALTER TABLE order_status_events DROP COLUMN IF EXISTS raw_response;
+ALTER TABLE order_status_events DROP COLUMN IF EXISTS accrual;
@@ -0,0 +1,6 @@
+ALTER TABLE order_status_events ADD COLUMN IF NOT EXISTS accrual NUMERIC(20, 2);
+ALTER TABLE order_status_events ADD COLUMN IF NOT EXISTS raw_response JSONB;
+
+-- статистика времени обработки строится по финальным статусам за период
+CREATE INDEX IF NOT EXISTS order_status_events_final_idx ON order_status_events (occurred_at)
+	WHERE status IN ('PROCESSED', 'INVALID', 'NORELEVANT');
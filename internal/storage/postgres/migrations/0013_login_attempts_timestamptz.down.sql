@@ -0,0 +1,8 @@
+ALTER TABLE login_lockouts
+	ALTER COLUMN locked_at TYPE TIMESTAMP,
+	ALTER COLUMN locked_until TYPE TIMESTAMP,
+	ALTER COLUMN unlocked_at TYPE TIMESTAMP;
+
+ALTER TABLE login_attempts
+	ALTER COLUMN last_failure_at TYPE TIMESTAMP,
+	ALTER COLUMN locked_until TYPE TIMESTAMP;
@@ -0,0 +1,10 @@
+-- сроки блокировок сравниваются с временем приложения, поэтому хранятся с часовым поясом:
+-- в TIMESTAMP pgx записывает местное время, а читает его как UTC
+ALTER TABLE login_attempts
+	ALTER COLUMN last_failure_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN locked_until TYPE TIMESTAMPTZ;
+
+ALTER TABLE login_lockouts
+	ALTER COLUMN locked_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN locked_until TYPE TIMESTAMPTZ,
+	ALTER COLUMN unlocked_at TYPE TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS rate_limit_buckets_full_at_idx;
+
+ALTER TABLE rate_limit_buckets DROP COLUMN IF EXISTS full_at;
+ALTER TABLE rate_limit_buckets ALTER COLUMN updated_at TYPE TIMESTAMP;
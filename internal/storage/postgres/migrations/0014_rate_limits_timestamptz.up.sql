@@ -0,0 +1,8 @@
+-- время пополнения корзин считается в базе, поэтому хранится с часовым поясом
+ALTER TABLE rate_limit_buckets ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+-- full_at - когда корзина заполнится и её можно удалить. Существующие корзины будут удалены
+-- при первой очистке и создадутся заново заполненными.
+ALTER TABLE rate_limit_buckets ADD COLUMN IF NOT EXISTS full_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_at_idx ON rate_limit_buckets (full_at);
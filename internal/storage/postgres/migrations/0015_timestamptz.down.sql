@@ -0,0 +1,21 @@
+ALTER TABLE idempotency_keys
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN expires_at TYPE TIMESTAMP;
+
+ALTER TABLE order_status_events ALTER COLUMN occurred_at TYPE TIMESTAMP;
+
+ALTER TABLE sessions
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN last_seen_at TYPE TIMESTAMP,
+	ALTER COLUMN expires_at TYPE TIMESTAMP,
+	ALTER COLUMN revoked_at TYPE TIMESTAMP;
+
+ALTER TABLE ledger_transactions ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE accrual_queue
+	ALTER COLUMN enqueued_at TYPE TIMESTAMP,
+	ALTER COLUMN next_attempt_at TYPE TIMESTAMP;
+
+ALTER TABLE withdrawals ALTER COLUMN processed_at TYPE TIMESTAMP;
+
+ALTER TABLE orders ALTER COLUMN uploaded_at TYPE TIMESTAMP;
@@ -0,0 +1,25 @@
+-- остальные отметки времени тоже хранятся с часовым поясом: в TIMESTAMP pgx записывает местное
+-- время сервера, а читает его как UTC, поэтому фильтры по периоду, курсоры и статистика по дням
+-- смещались на часовой пояс сервера. Существующие значения записаны по местному времени
+-- и переводятся по часовому поясу сессии, который должен совпадать с часовым поясом сервиса.
+ALTER TABLE orders ALTER COLUMN uploaded_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE withdrawals ALTER COLUMN processed_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE accrual_queue
+	ALTER COLUMN enqueued_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE ledger_transactions ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE sessions
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN last_seen_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE order_status_events ALTER COLUMN occurred_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE idempotency_keys
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
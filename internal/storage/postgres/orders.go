@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+// GetOrder возвращает заказ по номеру. Если заказ не найден, возвращает customerrors.ErrOrderNotFound.
+func (ps *PostgresStorage) GetOrder(ctx context.Context, orderNumber int64) (order models.Order, err error) {
+
+	query := `
+	SELECT order_num, user_id, status, COALESCE(points, 0), uploaded_at
+		FROM orders
+		WHERE order_num = $1;
+	`
+
+	err = ps.db.QueryRowContext(ctx, query, orderNumber).Scan(&order.OrderNumber, &order.UserID, &order.Status, &order.Accrual, &order.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return order, customerrors.ErrOrderNotFound
+	}
+
+	return order, err
+}
+
+// InsertOrders сохраняет пакет заказов в одной транзакции: новые заказы получают статус NEW и ставятся
+// в очередь на расчёт, уже загруженные не изменяются. Возвращает результат по каждому номеру:
+// constants.BatchAccepted, constants.BatchDuplicateOwn или constants.BatchDuplicateOther.
+func (ps *PostgresStorage) InsertOrders(ctx context.Context, userID int, orderNumbers []int64) (results map[int64]string, err error) {
+
+	// основной запрос видит таблицу orders до вставки, поэтому владелец находится только у ранее загруженных заказов
+	queryOrders := `
+	WITH input AS (
+		SELECT DISTINCT unnest($1::BIGINT[]) AS order_num
+	), inserted AS (
+		INSERT INTO orders (order_num, user_id, status, uploaded_at)
+			SELECT order_num, $2, $3, $4 FROM input
+			ON CONFLICT (order_num) DO NOTHING
+			RETURNING order_num
+	)
+	SELECT i.order_num, ins.order_num IS NOT NULL, COALESCE(o.user_id, 0)
+		FROM input i
+		LEFT JOIN inserted ins ON ins.order_num = i.order_num
+		LEFT JOIN orders o ON o.order_num = i.order_num;
+	`
+
+	queryOwners := `
+	SELECT order_num, user_id
+		FROM orders
+		WHERE order_num = ANY($1);
+	`
+
+	queryQueue := `
+	INSERT INTO accrual_queue (order_num, enqueued_at, next_attempt_at)
+		SELECT unnest($1::BIGINT[]), $2, $2;
+	`
+
+	queryEvents := `
+	INSERT INTO order_status_events (order_num, status, occurred_at)
+		SELECT unnest($1::BIGINT[]), $2, $3;
+	`
+
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	rows, err := tx.QueryContext(ctx, queryOrders, orderNumbers, userID, constants.New, now)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[int64]int, len(orderNumbers))
+	var accepted, concurrent []int64
+	for rows.Next() {
+		var orderNumber int64
+		var inserted bool
+		var ownerID int
+		if err := rows.Scan(&orderNumber, &inserted, &ownerID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка при считывании строки: %w", err)
+		}
+		switch {
+		case inserted:
+			accepted = append(accepted, orderNumber)
+		case ownerID == 0:
+			// заказ вставлен параллельной транзакцией после начала запроса
+			concurrent = append(concurrent, orderNumber)
+		default:
+			owners[orderNumber] = ownerID
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(concurrent) > 0 {
+		rows, err := tx.QueryContext(ctx, queryOwners, concurrent)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var orderNumber int64
+			var ownerID int
+			if err := rows.Scan(&orderNumber, &ownerID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("ошибка при считывании строки: %w", err)
+			}
+			owners[orderNumber] = ownerID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	results = make(map[int64]string, len(orderNumbers))
+	for _, orderNumber := range accepted {
+		results[orderNumber] = constants.BatchAccepted
+	}
+	for orderNumber, ownerID := range owners {
+		if ownerID == userID {
+			results[orderNumber] = constants.BatchDuplicateOwn
+		} else {
+			results[orderNumber] = constants.BatchDuplicateOther
+		}
+	}
+
+	if len(accepted) > 0 {
+		if _, err := tx.ExecContext(ctx, queryQueue, accepted, now); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, queryEvents, accepted, constants.New, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, tx.Commit()
+}
+
+// GetOrderHistory возвращает смены статуса заказа в порядке их наступления.
+func (ps *PostgresStorage) GetOrderHistory(ctx context.Context, orderNumber int64) (events []models.OrderStatusEvent, err error) {
+
+	query := `
+	SELECT status, occurred_at, accrual, raw_response
+		FROM order_status_events
+		WHERE order_num = $1
+		ORDER BY occurred_at, event_id;
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, orderNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.OrderStatusEvent
+		var raw sql.NullString
+		if err := rows.Scan(&event.Status, &event.OccurredAt, &event.Accrual, &raw); err != nil {
+			return nil, fmt.Errorf("ошибка при считывании строки: %w", err)
+		}
+		if raw.Valid {
+			event.RawResponse = json.RawMessage(raw.String)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// GetOrderLatencyStats возвращает по дням статистику времени от загрузки заказа до финального статуса
+// для заказов, получивших финальный статус в период [from, to). Если userID равен 0, учитываются все пользователи.
+func (ps *PostgresStorage) GetOrderLatencyStats(ctx context.Context, userID int, from time.Time, to time.Time) (stats []models.OrderLatencyStats, err error) {
+
+	// дни считаются по UTC, как и границы периода, независимо от часового пояса сессии
+	query := `
+	WITH latency AS (
+		SELECT date_trunc('day', e.occurred_at AT TIME ZONE 'UTC') AS day, EXTRACT(EPOCH FROM e.occurred_at - o.uploaded_at) AS seconds
+			FROM order_status_events e
+			JOIN orders o ON o.order_num = e.order_num
+			WHERE e.status IN ($1, $2, $3) AND e.occurred_at >= $4 AND e.occurred_at < $5 AND ($6 = 0 OR o.user_id = $6)
+	)
+	SELECT day, COUNT(*), AVG(seconds),
+			percentile_disc(0.5) WITHIN GROUP (ORDER BY seconds),
+			percentile_disc(0.95) WITHIN GROUP (ORDER BY seconds),
+			MAX(seconds)
+		FROM latency
+		GROUP BY day
+		ORDER BY day;
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, constants.Processed, constants.Invalid, constants.NotRelevant, from, to, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day models.OrderLatencyStats
+		var avg, p50, p95, max float64
+		if err := rows.Scan(&day.Day, &day.Orders, &avg, &p50, &p95, &max); err != nil {
+			return nil, fmt.Errorf("ошибка при считывании строки: %w", err)
+		}
+		day.Avg, day.P50, day.P95, day.Max = seconds(avg), seconds(p50), seconds(p95), seconds(max)
+		stats = append(stats, day)
+	}
+
+	return stats, rows.Err()
+}
+
+// insertOrderStatusEvent записывает смену статуса заказа в транзакции tx вместе с ответом системы расчёта,
+// если переход вызван им.
+func insertOrderStatusEvent(ctx context.Context, tx *sql.Tx, orderNumber int64, status string, at time.Time, accrual models.Points, raw json.RawMessage) error {
+
+	query := `
+	INSERT INTO order_status_events (order_num, status, occurred_at, accrual, raw_response)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	rawResponse := sql.NullString{String: string(raw), Valid: len(raw) > 0}
+
+	_, err := tx.ExecContext(ctx, query, orderNumber, status, at, accrual, rawResponse)
+	return err
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
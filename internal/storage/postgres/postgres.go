@@ -3,14 +3,19 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	_ "github.com/jackc/pgconn"
-	_ "github.com/jackc/pgerrcode"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
 	"github.com/maryakotova/gophermart/internal/models"
 	"go.uber.org/zap"
 )
@@ -19,12 +24,17 @@ type PostgresStorage struct {
 	db     *sql.DB
 	config *config.Config
 	logger *zap.Logger
-	mtx    sync.RWMutex
+
+	// фоновая очистка корзин ограничения частоты запросов; останавливается в Close
+	sweepMtx           sync.Mutex
+	lastRateLimitSweep time.Time
+	sweepCtx           context.Context
+	stopSweep          context.CancelFunc
+	sweeps             sync.WaitGroup
 }
 
-// ------------------------------------------------------------------------------------
-// нужно ли при таком создании конекшен вызывать defer db.Close(), если да, то где?
-// ------------------------------------------------------------------------------------
+// NewPostgresStorage открывает пул соединений с базой. Пул закрывается методом Close
+// при остановке приложения, после завершения запросов и воркеров.
 func NewPostgresStorage(cfg *config.Config, logger *zap.Logger) (*PostgresStorage, error) {
 	db, err := sql.Open("pgx", cfg.DatabaseURI)
 	if err != nil {
@@ -33,87 +43,33 @@ func NewPostgresStorage(cfg *config.Config, logger *zap.Logger) (*PostgresStorag
 		logger.Error(err.Error())
 		return nil, err
 	}
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
 	return &PostgresStorage{
-		db:     db,
-		config: cfg,
-		logger: logger,
+		db:        db,
+		config:    cfg,
+		logger:    logger,
+		sweepCtx:  sweepCtx,
+		stopSweep: stopSweep,
 	}, nil
 }
 
-func (ps *PostgresStorage) Bootstrap(ctx context.Context) error {
-
-	tx, err := ps.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		user_id SERIAL PRIMARY KEY,
-		user_name VARCHAR(50) UNIQUE NOT NULL,
-		password VARCHAR(255) NOT NULL
-	);
-	`
-
-	_, err = tx.ExecContext(ctx, query)
-	if err != nil {
-		ps.logger.Error(err.Error())
-		return err
-	}
-
-	query = `
-	CREATE TABLE IF NOT EXISTS orders (
-		order_num BIGINT PRIMARY KEY,
-		user_id INT NOT NULL,
-		status VARCHAR(10) NOT NULL,
-		uploaded_at TIMESTAMP NOT NULL,
-		points DOUBLE PRECISION,
-		FOREIGN KEY (user_id) REFERENCES users(user_id)
-	);
-	`
-
-	_, err = tx.ExecContext(ctx, query)
-	if err != nil {
-		ps.logger.Error(err.Error())
-		return err
-	}
-
-	query = `
-	CREATE TABLE IF NOT EXISTS withdrawals (
-		order_num BIGINT PRIMARY KEY,
-		user_id INT NOT NULL,
-		processed_at TIMESTAMP NOT NULL,
-		points DOUBLE PRECISION,
-		FOREIGN KEY (user_id) REFERENCES users(user_id)
-	);
-	`
-
-	_, err = tx.ExecContext(ctx, query)
-	if err != nil {
-		ps.logger.Error(err.Error())
-		return err
-	}
-
-	query = `
-	CREATE TABLE IF NOT EXISTS balance (
-		user_id INT PRIMARY KEY,
-		sum DOUBLE PRECISION NOT NULL,
-		FOREIGN KEY (user_id) REFERENCES users(user_id)
-	);
-	`
+// Close прерывает фоновую очистку, дожидается её завершения и закрывает пул соединений.
+func (ps *PostgresStorage) Close() error {
+	ps.sweepMtx.Lock()
+	ps.stopSweep()
+	ps.sweepMtx.Unlock()
+	ps.sweeps.Wait()
 
-	_, err = tx.ExecContext(ctx, query)
-	if err != nil {
-		ps.logger.Error(err.Error())
-		return err
-	}
+	return ps.db.Close()
+}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error creating tables: %v", err)
-	}
+func (ps *PostgresStorage) Ping(ctx context.Context) error {
+	return ps.db.PingContext(ctx)
+}
 
-	return nil
+// Bootstrap приводит схему базы данных к актуальной версии.
+func (ps *PostgresStorage) Bootstrap(ctx context.Context) error {
+	return ps.MigrateUp(ctx)
 }
 
 func (ps *PostgresStorage) GetUserID(ctx context.Context, userName string) (userID int, err error) {
@@ -123,9 +79,10 @@ func (ps *PostgresStorage) GetUserID(ctx context.Context, userName string) (user
 		FROM users 
 		WHERE user_name = $1;
 	`
-	ps.mtx.Lock()
-	err = ps.db.QueryRowContext(ctx, query, userName).Scan(userID)
-	ps.mtx.Unlock()
+	err = ps.db.QueryRowContext(ctx, query, userName).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, nil
+	}
 	if err != nil {
 		return -1, err
 	}
@@ -145,10 +102,12 @@ func (ps *PostgresStorage) CreateUser(ctx context.Context, login string, hashedP
 		RETURNING user_id;
 	`
 
-	ps.mtx.Lock()
-	err = ps.db.QueryRowContext(ctx, query, login, hashedPassword).Scan(userID)
-	ps.mtx.Unlock()
+	err = ps.db.QueryRowContext(ctx, query, login, hashedPassword).Scan(&userID)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return -1, customerrors.ErrUsernameTaken
+		}
 		return -1, err
 	}
 
@@ -162,9 +121,10 @@ func (ps *PostgresStorage) GetUserAuthData(ctx context.Context, login string) (u
 		FROM users 
 		WHERE user_name = $1;
 	`
-	ps.mtx.Lock()
-	err = ps.db.QueryRowContext(ctx, query, login).Scan(userID, hashedPassword)
-	ps.mtx.Unlock()
+	err = ps.db.QueryRowContext(ctx, query, login).Scan(&userID, &hashedPassword)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, "", nil
+	}
 	if err != nil {
 		return -1, "", err
 	}
@@ -172,6 +132,18 @@ func (ps *PostgresStorage) GetUserAuthData(ctx context.Context, login string) (u
 	return
 }
 
+func (ps *PostgresStorage) UpdatePasswordHash(ctx context.Context, userID int, hashedPassword string) error {
+
+	query := `
+	UPDATE users
+		SET password = $2
+		WHERE user_id = $1;
+	`
+	_, err := ps.db.ExecContext(ctx, query, userID, hashedPassword)
+
+	return err
+}
+
 func (ps *PostgresStorage) GetUserByOrderNum(ctx context.Context, orderNumber int64) (userID int, err error) {
 
 	query := `
@@ -180,9 +152,10 @@ func (ps *PostgresStorage) GetUserByOrderNum(ctx context.Context, orderNumber in
 		WHERE order_num = $1;
 	`
 
-	ps.mtx.Lock()
-	err = ps.db.QueryRowContext(ctx, query, orderNumber).Scan(userID)
-	ps.mtx.Unlock()
+	err = ps.db.QueryRowContext(ctx, query, orderNumber).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
 	if err != nil {
 		return -1, err
 	}
@@ -190,72 +163,172 @@ func (ps *PostgresStorage) GetUserByOrderNum(ctx context.Context, orderNumber in
 	return
 }
 
-func (ps *PostgresStorage) InsertOrder(ctx context.Context, userID int, accrualResponce models.AccrualSystemResponce) error {
+// InsertOrder сохраняет заказ в статусе NEW и в той же транзакции ставит его в очередь на расчёт начислений.
+// Если заказ уже загружен, возвращает customerrors.ErrOrderLoadedByUser или customerrors.ErrOrderLoadedByAnotherUser.
+func (ps *PostgresStorage) InsertOrder(ctx context.Context, userID int, orderNumber int64) error {
 
-	query := `
-	INSERT INTO orders (order_num, user_id, status, uploaded_at, points)
-		VALUES ($1, $2, $3, $4, $5);
+	queryOrder := `
+	INSERT INTO orders (order_num, user_id, status, uploaded_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_num) DO NOTHING;
+	`
+
+	queryOwner := `
+	SELECT user_id
+		FROM orders
+		WHERE order_num = $1;
+	`
+
+	queryQueue := `
+	INSERT INTO accrual_queue (order_num, enqueued_at, next_attempt_at)
+		VALUES ($1, $2, $2);
 	`
 
-	ps.mtx.Lock()
-	_, err := ps.db.ExecContext(ctx, query, accrualResponce.Order, userID, accrualResponce.Status, time.Now(), accrualResponce.Accrual)
-	ps.mtx.Unlock()
+	tx, err := ps.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	now := time.Now()
 
-	return nil
+	result, err := tx.ExecContext(ctx, queryOrder, orderNumber, userID, constants.New, now)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if inserted == 0 {
+		var ownerID int
+		err = tx.QueryRowContext(ctx, queryOwner, orderNumber).Scan(&ownerID)
+		if err != nil {
+			return err
+		}
+		if ownerID == userID {
+			return customerrors.ErrOrderLoadedByUser
+		}
+		return customerrors.ErrOrderLoadedByAnotherUser
+	}
+
+	_, err = tx.ExecContext(ctx, queryQueue, orderNumber, now)
+	if err != nil {
+		return err
+	}
+
+	err = insertOrderStatusEvent(ctx, tx, orderNumber, constants.New, now, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// UpdateOrder обновляет статус заказа и, если расчёт окончен, записывает начисление баллов в журнал.
+// Заказ в финальном статусе не обновляется повторно, поэтому баллы начисляются ровно один раз.
+// Смена статуса записывается в историю заказа.
 func (ps *PostgresStorage) UpdateOrder(ctx context.Context, accrualResponce models.AccrualSystemResponce) error {
 
-	queryWoPoints := `
-	UPDATE orders 
-		SET status = $1
-		WHERE order_num = $2;
+	queryOrder := `
+	WITH old AS (
+		SELECT order_num, status
+			FROM orders
+			WHERE order_num = $3 AND status NOT IN ($4, $5, $6)
+			FOR UPDATE
+	)
+	UPDATE orders o
+		SET status = $1, points = $2
+		FROM old
+		WHERE o.order_num = old.order_num
+		RETURNING o.user_id, old.status;
 	`
 
-	queryWPoints := `
-	UPDATE orders 
-		SET status = $1, points = $2
-		WHERE order_num = $3;
+	queryQueue := `
+	DELETE FROM accrual_queue
+		WHERE order_num = $1;
 	`
-	var err error
 
-	ps.mtx.Lock()
-	if accrualResponce.Accrual > 0 {
-		_, err = ps.db.ExecContext(ctx, queryWPoints, accrualResponce.Status, accrualResponce.Accrual, accrualResponce.Order)
-	} else {
-		_, err = ps.db.ExecContext(ctx, queryWoPoints, accrualResponce.Status, accrualResponce.Order)
+	tx, err := ps.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
-	ps.mtx.Unlock()
+	defer tx.Rollback()
 
+	orderNumber, err := strconv.ParseInt(accrualResponce.Order, 10, 64)
+	if err != nil {
+		return fmt.Errorf("некорректный номер заказа в ответе системы расчёта: %w", err)
+	}
+
+	var userID int
+	var oldStatus string
+	err = tx.QueryRowContext(ctx, queryOrder, accrualResponce.Status, accrualResponce.Accrual, orderNumber,
+		constants.Processed, constants.Invalid, constants.NotRelevant).Scan(&userID, &oldStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		// заказ уже в финальном статусе
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if oldStatus != accrualResponce.Status {
+		err = insertOrderStatusEvent(ctx, tx, orderNumber, accrualResponce.Status, time.Now(), accrualResponce.Accrual, accrualResponce.Raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	if accrualResponce.Status == constants.Processed && accrualResponce.Accrual > 0 {
+		err = postLedgerTransaction(ctx, tx, constants.LedgerAccrual, userID, sql.NullInt64{Int64: orderNumber, Valid: true}, accrualResponce.Accrual, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	if isFinalStatus(accrualResponce.Status) {
+		_, err = tx.ExecContext(ctx, queryQueue, accrualResponce.Order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (ps *PostgresStorage) GetOrdersForUser(ctx context.Context, userID int) (orders []models.OrderList, err error) {
+// DequeueOrders выбирает из очереди заказы, готовые к обработке, и откладывает их следующую попытку на время lease.
+// Если заказ не будет доведён до финального статуса, он снова станет доступен по истечении lease.
+func (ps *PostgresStorage) DequeueOrders(ctx context.Context, limit int, lease time.Duration) (orders []models.OrderToProcess, err error) {
 
 	query := `
-	SELECT order_num, status, uploaded_at, points
-		FROM orders
-		WHERE iser_id = $1
-		ORDER BY uploaded_at DESC;
+	WITH due AS (
+		SELECT order_num
+			FROM accrual_queue
+			WHERE next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+	)
+	UPDATE accrual_queue q
+		SET next_attempt_at = $3, attempts = q.attempts + 1
+		FROM due, orders o
+		WHERE q.order_num = due.order_num AND o.order_num = q.order_num
+		RETURNING q.order_num, o.status, COALESCE(o.points, 0);
 	`
-	ps.mtx.Lock()
-	rows, err := ps.db.QueryContext(ctx, query, userID)
-	ps.mtx.Unlock()
-	defer rows.Close()
+
+	now := time.Now()
+
+	rows, err := ps.db.QueryContext(ctx, query, now, limit, now.Add(lease))
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var order models.OrderList
-		err := rows.Scan(&order.OrderNumber, &order.Status, &order.UploadedAt, &order.Accrual)
+		var order models.OrderToProcess
+		err := rows.Scan(&order.OrderNumber, &order.Status, &order.Accrual)
 		if err != nil {
 			err = fmt.Errorf("ошибка при считывании строки: %w", err)
 			return nil, err
@@ -263,114 +336,131 @@ func (ps *PostgresStorage) GetOrdersForUser(ctx context.Context, userID int) (or
 		orders = append(orders, order)
 	}
 
-	return orders, nil
+	return orders, rows.Err()
 }
 
-func (ps *PostgresStorage) UpdateBalance(ctx context.Context, userID int, points float64) error {
+// CountQueuedOrders возвращает количество заказов, ожидающих расчёта начислений.
+func (ps *PostgresStorage) CountQueuedOrders(ctx context.Context) (count int, err error) {
 
-	query := `
-	UPDATE balance
-		SET sum = $1
-		WHERE user_id = S2;
-	`
-	ps.mtx.Lock()
-	_, err := ps.db.ExecContext(ctx, query, points, userID)
-	ps.mtx.Unlock()
-	if err != nil {
-		return err
-	}
+	err = ps.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM accrual_queue;`).Scan(&count)
 
-	return nil
+	return count, err
 }
 
-func (ps *PostgresStorage) GetCurrentBalance(ctx context.Context, userID int) (balance float64, err error) {
+// GetOrdersForUser возвращает страницу заказов пользователя. Выборка идёт по индексу
+// (user_id, uploaded_at, order_num), поэтому не зависит от общего количества заказов.
+func (ps *PostgresStorage) GetOrdersForUser(ctx context.Context, userID int, query models.ListQuery) (orders []models.OrderList, err error) {
 
-	query := `
-	SELECT sum 
-		FROM balance 
-		WHERE user_name = $1;
-	`
-	ps.mtx.Lock()
-	err = ps.db.QueryRowContext(ctx, query, userID).Scan(balance)
-	ps.mtx.Unlock()
+	where, args := listConditions(userID, "uploaded_at", query)
+	if len(query.Statuses) > 0 {
+		args = append(args, query.Statuses)
+		where = append(where, fmt.Sprintf("status = ANY($%d)", len(args)))
+	}
+	args = append(args, query.Limit)
+
+	statement := fmt.Sprintf(`
+	SELECT order_num, status, uploaded_at, points
+		FROM orders
+		WHERE %s
+		ORDER BY uploaded_at %s, order_num %[2]s
+		LIMIT $%d;
+	`, strings.Join(where, " AND "), listDirection(query), len(args))
+
+	rows, err := ps.db.QueryContext(ctx, statement, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return balance, nil
+	for rows.Next() {
+		var order models.OrderList
+		err := rows.Scan(&order.OrderNumber, &order.Status, &order.UploadedAt, &order.Accrual)
+		if err != nil {
+			err = fmt.Errorf("ошибка при считывании строки: %w", err)
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
 }
 
-func (ps *PostgresStorage) GetWithdrawalSum(ctx context.Context, userID int) (withdrawalSum float64, err error) {
+// Withdraw списывает баллы в одной транзакции: блокирует строку остатка пользователя, проверяет,
+// что средств достаточно, сохраняет списание и записывает его в журнал баллов.
+// При недостатке средств возвращает customerrors.ErrLowBalance.
+func (ps *PostgresStorage) Withdraw(ctx context.Context, userID int, orderNumber int64, points models.Points) error {
 
-	query := ` 
-	SELECT SUM(points) AS total_points
-		FROM withdrawals
-		WHERE user_id = $1;
+	queryBalance := `
+	SELECT sum
+		FROM balance
+		WHERE user_id = $1
+		FOR UPDATE;
 	`
 
-	ps.mtx.Lock()
-	err = ps.db.QueryRowContext(ctx, query, userID).Scan(withdrawalSum)
-	ps.mtx.Unlock()
+	queryWithdrawal := `
+	INSERT INTO withdrawals (order_num, user_id, processed_at, points)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	tx, err := ps.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	defer tx.Rollback()
 
-	return withdrawalSum, nil
-}
-
-func (ps *PostgresStorage) IncreaseBalance(ctx context.Context, userID int, points float64) error {
-
-	query := `
-	INSERT INTO balance (user_id, sum)
-		VALUES ($1, $2)
-		ON CONFLICT (user_id) 
-		DO UPDATE SET sum = balance.sum + EXCLUDED.sum;
-		`
-	_, err := ps.db.ExecContext(ctx, query, userID, points)
+	var balance models.Points
+	err = tx.QueryRowContext(ctx, queryBalance, userID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return customerrors.ErrLowBalance
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func (ps *PostgresStorage) InsertWithdrawal(ctx context.Context, userID int, orderNumber int64, points float64) error {
-
-	query := `
-	INSERT INTO orders (order_num, user_id, processed_at, points)
-		VALUES ($1, $2, $3, $4);
-	`
+	if balance < points {
+		return customerrors.ErrLowBalance
+	}
 
-	ps.mtx.Lock()
-	_, err := ps.db.ExecContext(ctx, query, orderNumber, userID, time.Now(), points)
-	ps.mtx.Unlock()
+	_, err = tx.ExecContext(ctx, queryWithdrawal, orderNumber, userID, time.Now(), points)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return customerrors.ErrWithdrawalExists
+		}
 		return err
 	}
 
-	return nil
+	err = postLedgerTransaction(ctx, tx, constants.LedgerWithdrawal, userID, sql.NullInt64{Int64: orderNumber, Valid: true}, -points, "")
+	if err != nil {
+		return err
+	}
 
+	return tx.Commit()
 }
 
-func (ps *PostgresStorage) GetWithdrawalsForUser(ctx context.Context, userID int) (withdrawals []models.Withdrawals, err error) {
+// GetWithdrawalsForUser возвращает страницу списаний пользователя по индексу (user_id, processed_at, order_num).
+func (ps *PostgresStorage) GetWithdrawalsForUser(ctx context.Context, userID int, query models.ListQuery) (withdrawals []models.Withdrawals, err error) {
 
-	query := `
+	where, args := listConditions(userID, "processed_at", query)
+	args = append(args, query.Limit)
+
+	statement := fmt.Sprintf(`
 	SELECT order_num, points, processed_at
 		FROM withdrawals
-		WHERE iser_id = $1
-		ORDER BY processed_at DESC;
-	`
-	ps.mtx.Lock()
-	rows, err := ps.db.QueryContext(ctx, query, userID)
-	ps.mtx.Unlock()
-	defer rows.Close()
+		WHERE %s
+		ORDER BY processed_at %s, order_num %[2]s
+		LIMIT $%d;
+	`, strings.Join(where, " AND "), listDirection(query), len(args))
+
+	rows, err := ps.db.QueryContext(ctx, statement, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var withdrawal models.Withdrawals
-		err := rows.Scan(&withdrawal.OrderNumber, &withdrawal.ProcessedAt, &withdrawal.Sum)
+		err := rows.Scan(&withdrawal.OrderNumber, &withdrawal.Sum, &withdrawal.ProcessedAt)
 		if err != nil {
 			err = fmt.Errorf("ошибка при считывании строки: %w", err)
 			return nil, err
@@ -378,5 +468,45 @@ func (ps *PostgresStorage) GetWithdrawalsForUser(ctx context.Context, userID int
 		withdrawals = append(withdrawals, withdrawal)
 	}
 
-	return withdrawals, nil
+	return withdrawals, rows.Err()
+}
+
+// listConditions строит общие условия постраничной выборки: пользователь, период и позиция курсора.
+// timeColumn подставляется в запрос как есть и не должен приходить от клиента.
+func listConditions(userID int, timeColumn string, query models.ListQuery) (where []string, args []interface{}) {
+
+	args = append(args, userID)
+	where = append(where, "user_id = $1")
+
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		where = append(where, fmt.Sprintf("%s >= $%d", timeColumn, len(args)))
+	}
+
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		where = append(where, fmt.Sprintf("%s < $%d", timeColumn, len(args)))
+	}
+
+	if query.Cursor != nil {
+		operator := "<"
+		if query.Ascending {
+			operator = ">"
+		}
+		args = append(args, query.Cursor.At, query.Cursor.OrderNumber)
+		where = append(where, fmt.Sprintf("(%s, order_num) %s ($%d, $%d)", timeColumn, operator, len(args)-1, len(args)))
+	}
+
+	return where, args
+}
+
+func listDirection(query models.ListQuery) string {
+	if query.Ascending {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+func isFinalStatus(status string) bool {
+	return status == constants.Processed || status == constants.Invalid || status == constants.NotRelevant
 }
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rateLimitSweepInterval - как часто удаляются заполненные корзины, чтобы таблица не росла бесконечно.
+const rateLimitSweepInterval = time.Minute
+
+// refillTokens - количество токенов в корзине с псевдонимом %s на текущий момент; $2 - ёмкость, $3 - интервал в секундах.
+const refillTokens = `LEAST($2::float8, %[1]s.tokens + GREATEST(EXTRACT(EPOCH FROM now() - %[1]s.updated_at)::float8, 0) / $3::float8)`
+
+// TakeRateLimitToken забирает токен из корзины ограничения частоты запросов. Корзины хранятся в базе,
+// поэтому ограничение действует на все экземпляры сервиса. Корзина создаётся, пополняется и уменьшается
+// одним запросом под блокировкой строки, чтобы параллельные запросы и очистка не мешали друг другу.
+// Время пополнения берётся из базы, а не из now: часы экземпляров сервиса могут расходиться.
+func (ps *PostgresStorage) TakeRateLimitToken(ctx context.Context, key string, capacity int, interval time.Duration, now time.Time) (allowed bool, tokens float64, err error) {
+
+	ps.sweepRateLimitBuckets(now)
+
+	// если токена нет, строка не изменяется, и остаток читается из снимка на начало запроса
+	query := fmt.Sprintf(`
+	WITH taken AS (
+		INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, updated_at, full_at)
+			VALUES ($1, $2::float8 - 1, now(), now() + $3::float8 * interval '1 second')
+			ON CONFLICT (bucket_key) DO UPDATE
+			SET tokens = %[1]s - 1,
+				updated_at = GREATEST(b.updated_at, now()),
+				full_at = GREATEST(b.updated_at, now()) + ($2::float8 - %[1]s + 1) * $3::float8 * interval '1 second'
+			WHERE %[1]s >= 1
+			RETURNING tokens
+	)
+	SELECT true, tokens FROM taken
+	UNION ALL
+	SELECT false, %[2]s
+		FROM rate_limit_buckets r
+		WHERE r.bucket_key = $1 AND NOT EXISTS (SELECT 1 FROM taken);
+	`, fmt.Sprintf(refillTokens, "b"), fmt.Sprintf(refillTokens, "r"))
+
+	err = ps.db.QueryRowContext(ctx, query, key, float64(capacity), interval.Seconds()).Scan(&allowed, &tokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		// корзину создал параллельный запрос после начала нашего, и токенов в ней уже нет
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, tokens, nil
+}
+
+// sweepRateLimitBuckets удаляет заполненные корзины не чаще раза в rateLimitSweepInterval, не задерживая запрос:
+// они ничем не отличаются от новых.
+func (ps *PostgresStorage) sweepRateLimitBuckets(now time.Time) {
+
+	ps.sweepMtx.Lock()
+	if ps.sweepCtx.Err() != nil || now.Sub(ps.lastRateLimitSweep) < rateLimitSweepInterval {
+		ps.sweepMtx.Unlock()
+		return
+	}
+	ps.lastRateLimitSweep = now
+	ps.sweeps.Add(1)
+	ps.sweepMtx.Unlock()
+
+	go func() {
+		defer ps.sweeps.Done()
+
+		_, err := ps.db.ExecContext(ps.sweepCtx, `DELETE FROM rate_limit_buckets WHERE full_at <= now();`)
+		if err != nil && ps.sweepCtx.Err() == nil {
+			ps.logger.Error("ошибка при удалении заполненных корзин ограничения частоты запросов", zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/models"
+)
+
+func (ps *PostgresStorage) CreateSession(ctx context.Context, session models.Session) (sessionID int64, err error) {
+
+	query := `
+	INSERT INTO sessions (user_id, refresh_hash, user_agent, ip, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $5, $6)
+		RETURNING session_id;
+	`
+
+	err = ps.db.QueryRowContext(ctx, query, session.UserID, session.RefreshHash, session.UserAgent, session.IP,
+		session.CreatedAt, session.ExpiresAt).Scan(&sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return sessionID, nil
+}
+
+// RotateSession заменяет токен обновления действующей сессии на новый. Старый токен после этого
+// недействителен. Если сессия не найдена, отозвана или истекла, возвращает customerrors.ErrSessionNotFound.
+func (ps *PostgresStorage) RotateSession(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (session models.Session, err error) {
+
+	query := `
+	UPDATE sessions
+		SET refresh_hash = $2, expires_at = $3, last_seen_at = $4
+		WHERE refresh_hash = $1 AND revoked_at IS NULL AND expires_at > $4
+		RETURNING session_id, user_id, user_agent, ip, created_at, last_seen_at, expires_at;
+	`
+
+	err = ps.db.QueryRowContext(ctx, query, oldHash, newHash, expiresAt, time.Now()).Scan(
+		&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, customerrors.ErrSessionNotFound
+	}
+	if err != nil {
+		return session, err
+	}
+
+	session.RefreshHash = newHash
+	return session, nil
+}
+
+// TouchSession отмечает обращение в рамках сессии и сообщает, действует ли она.
+func (ps *PostgresStorage) TouchSession(ctx context.Context, sessionID int64) (active bool, err error) {
+
+	query := `
+	UPDATE sessions
+		SET last_seen_at = $2
+		WHERE session_id = $1 AND revoked_at IS NULL AND expires_at > $2;
+	`
+
+	result, err := ps.db.ExecContext(ctx, query, sessionID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
+func (ps *PostgresStorage) RevokeSession(ctx context.Context, userID int, sessionID int64) error {
+
+	query := `
+	UPDATE sessions
+		SET revoked_at = $3
+		WHERE session_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+
+	_, err := ps.db.ExecContext(ctx, query, sessionID, userID, time.Now())
+
+	return err
+}
+
+func (ps *PostgresStorage) RevokeUserSessions(ctx context.Context, userID int) error {
+
+	query := `
+	UPDATE sessions
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL;
+	`
+
+	_, err := ps.db.ExecContext(ctx, query, userID, time.Now())
+
+	return err
+}
+
+// GetUserSessions возвращает действующие сессии пользователя, начиная с последней активной.
+func (ps *PostgresStorage) GetUserSessions(ctx context.Context, userID int) (sessions []models.Session, err error) {
+
+	query := `
+	SELECT session_id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC;
+	`
+
+	rows, err := ps.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session := models.Session{UserID: userID}
+		err := rows.Scan(&session.ID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+		if err != nil {
+			err = fmt.Errorf("ошибка при считывании строки: %w", err)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/maryakotova/gophermart/internal/config"
 	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
 	"github.com/maryakotova/gophermart/internal/storage/postgres"
 	"go.uber.org/zap"
 )
@@ -13,20 +15,55 @@ type Storage interface {
 	GetUserID(ctx context.Context, userName string) (userID int, err error)
 	CreateUser(ctx context.Context, login string, hashedPassword string) (userID int, err error)
 	GetUserAuthData(ctx context.Context, login string) (userID int, hashedPassword string, err error)
+	UpdatePasswordHash(ctx context.Context, userID int, hashedPassword string) error
 	GetUserByOrderNum(ctx context.Context, orderNumber int64) (userID int, err error)
-	InsertOrder(ctx context.Context, userID int, accrualResponce models.AccrualSystemResponce) error
-	GetOrdersForUser(ctx context.Context, userID int) (orders []models.OrderList, err error)
-	UpdateBalance(ctx context.Context, userID int, points float64) error
-	GetCurrentBalance(ctx context.Context, userID int) (balance float64, err error)
-	GetWithdrawalSum(ctx context.Context, userID int) (withdrawalSum float64, err error)
-	IncreaseBalance(ctx context.Context, userID int, points float64) error
-	InsertWithdrawal(ctx context.Context, userID int, orderNumber int64, points float64) error
-	GetWithdrawalsForUser(ctx context.Context, userID int) (withdrawals []models.Withdrawals, err error)
+	InsertOrder(ctx context.Context, userID int, orderNumber int64) error
+	InsertOrders(ctx context.Context, userID int, orderNumbers []int64) (results map[int64]string, err error)
+	UpdateOrder(ctx context.Context, accrualResponce models.AccrualSystemResponce) error
+	DequeueOrders(ctx context.Context, limit int, lease time.Duration) (orders []models.OrderToProcess, err error)
+	CountQueuedOrders(ctx context.Context) (count int, err error)
+	GetOrder(ctx context.Context, orderNumber int64) (order models.Order, err error)
+	GetOrderHistory(ctx context.Context, orderNumber int64) (events []models.OrderStatusEvent, err error)
+	GetOrderLatencyStats(ctx context.Context, userID int, from time.Time, to time.Time) (stats []models.OrderLatencyStats, err error)
+	GetOrdersForUser(ctx context.Context, userID int, query models.ListQuery) (orders []models.OrderList, err error)
+	GetCurrentBalance(ctx context.Context, userID int) (balance models.Points, err error)
+	GetWithdrawalSum(ctx context.Context, userID int) (withdrawalSum models.Points, err error)
+	AdjustBalance(ctx context.Context, userID int, points models.Points, comment string) error
+	GetLedgerEntries(ctx context.Context, userID int) (entries []models.LedgerEntry, err error)
+	GetLedgerBalance(ctx context.Context, userID int) (balance models.Points, err error)
+	Withdraw(ctx context.Context, userID int, orderNumber int64, points models.Points) error
+	GetWithdrawalsForUser(ctx context.Context, userID int, query models.ListQuery) (withdrawals []models.Withdrawals, err error)
+	CreateSession(ctx context.Context, session models.Session) (sessionID int64, err error)
+	RotateSession(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (session models.Session, err error)
+	TouchSession(ctx context.Context, sessionID int64) (active bool, err error)
+	RevokeSession(ctx context.Context, userID int, sessionID int64) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+	GetUserSessions(ctx context.Context, userID int) (sessions []models.Session, err error)
+	GetLoginAttempts(ctx context.Context, key string) (attempts models.LoginAttempts, err error)
+	RegisterLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (attempts models.LoginAttempts, err error)
+	DelayLogin(ctx context.Context, key string, until time.Time) error
+	LockLogin(ctx context.Context, lockout models.LoginLockout) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	UnlockLogin(ctx context.Context, key string, at time.Time) error
+	GetLoginLockouts(ctx context.Context, key string, limit int) (lockouts []models.LoginLockout, err error)
+	BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord, staleBefore time.Time) (stored models.IdempotencyRecord, created bool, err error)
+	CompleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error
+	DeleteIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error
+	PurgeIdempotentRequests(ctx context.Context, before time.Time) (deleted int64, err error)
+	Ping(ctx context.Context) error
+	PendingMigrations(ctx context.Context) (pending int, err error)
+	Close() error
 }
 
 type StorageFactory struct{}
 
+// NewStorage создаёт хранилище в Postgres, а если адрес базы данных не задан - хранилище в памяти.
 func (f *StorageFactory) NewStorage(cfg *config.Config, logger *zap.Logger) (Storage, error) {
+	if cfg.DatabaseURI == "" {
+		logger.Info("адрес базы данных не задан, данные хранятся в памяти")
+		return memory.NewMemoryStorage(cfg, logger), nil
+	}
+
 	postgres, err := postgres.NewPostgresStorage(cfg, logger)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,137 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"github.com/maryakotova/gophermart/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+const (
+	withdrawGoroutines = 50
+	withdrawBalance    = models.Points(10000) // 100 баллов
+	withdrawSum        = models.Points(700)   // 7 баллов: успешно ровно 14 списаний
+)
+
+func TestWithdrawConcurrentMemory(t *testing.T) {
+	store := memory.NewMemoryStorage(&config.Config{}, zap.NewNop())
+	testWithdrawConcurrent(t, []storage.Storage{store})
+}
+
+// TestWithdrawConcurrentPostgres списывает баллы через несколько независимых пулов соединений,
+// как это делали бы несколько экземпляров сервиса. Запускается, если задан DATABASE_URI.
+func TestWithdrawConcurrentPostgres(t *testing.T) {
+	testWithdrawConcurrent(t, postgresStores(t, 4))
+}
+
+// postgresStores открывает n независимых пулов соединений с базой из DATABASE_URI
+// и приводит схему к актуальной версии. Без DATABASE_URI тест пропускается.
+func postgresStores(t *testing.T, n int) []storage.Storage {
+	t.Helper()
+
+	uri := os.Getenv("DATABASE_URI")
+	if uri == "" {
+		t.Skip("DATABASE_URI не задан")
+	}
+
+	cfg := &config.Config{DatabaseURI: uri}
+
+	var stores []storage.Storage
+	for i := 0; i < n; i++ {
+		store, err := postgres.NewPostgresStorage(cfg, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		if i == 0 {
+			if err := store.Bootstrap(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stores = append(stores, store)
+	}
+
+	return stores
+}
+
+func testWithdrawConcurrent(t *testing.T, stores []storage.Storage) {
+
+	ctx := context.Background()
+	login := fmt.Sprintf("withdraw-test-%d", time.Now().UnixNano())
+
+	userID, err := stores[0].CreateUser(ctx, login, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stores[0].AdjustBalance(ctx, userID, withdrawBalance, "пополнение для теста"); err != nil {
+		t.Fatal(err)
+	}
+
+	// номера заказов уникальны для каждого запуска, чтобы тест можно было повторять на одной базе
+	baseOrder := time.Now().UnixNano() / 1000 * 100
+
+	var wg sync.WaitGroup
+	errs := make([]error, withdrawGoroutines)
+	start := make(chan struct{})
+
+	for i := 0; i < withdrawGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			store := stores[i%len(stores)]
+			errs[i] = store.Withdraw(ctx, userID, baseOrder+int64(i), withdrawSum)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, customerrors.ErrLowBalance):
+		default:
+			t.Errorf("списание %d: неожиданная ошибка: %v", i, err)
+		}
+	}
+
+	want := int(withdrawBalance / withdrawSum)
+	if succeeded != want {
+		t.Errorf("успешных списаний %d, ожидалось %d", succeeded, want)
+	}
+
+	balance, err := stores[0].GetCurrentBalance(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance < 0 {
+		t.Fatalf("отрицательный остаток: %s", balance)
+	}
+	if wantBalance := withdrawBalance - withdrawSum*models.Points(want); balance != wantBalance {
+		t.Errorf("остаток %s, ожидалось %s", balance, wantBalance)
+	}
+
+	ledgerBalance, err := stores[0].GetLedgerBalance(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledgerBalance != balance {
+		t.Errorf("остаток по журналу %s расходится с остатком %s", ledgerBalance, balance)
+	}
+}
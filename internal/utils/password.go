@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// алгоритмы хеширования паролей
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// maxPasswordLength - ограничение bcrypt: более длинные пароли он не принимает.
+const maxPasswordLength = 72
+
+// PasswordHasher хеширует и проверяет пароли в одном формате.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encoded string) (bool, error)
+	// Supports сообщает, что хеш записан в формате этого алгоритма.
+	Supports(encoded string) bool
+	// NeedsRehash сообщает, что хеш получен с параметрами, отличными от текущих.
+	NeedsRehash(encoded string) bool
+}
+
+// Passwords хеширует новые пароли выбранным в конфигурации алгоритмом, а проверяет
+// пароли во всех поддерживаемых форматах, чтобы можно было сменить алгоритм без сброса паролей.
+type Passwords struct {
+	current PasswordHasher
+	hashers []PasswordHasher
+}
+
+func NewPasswords(cfg *config.Config) (*Passwords, error) {
+
+	bcryptCost := cfg.BcryptCost
+	if bcryptCost == 0 {
+		bcryptCost = bcrypt.DefaultCost
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("стоимость bcrypt должна быть от %d до %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if cfg.Argon2Memory < 0 || cfg.Argon2Iterations < 0 || cfg.Argon2Parallelism < 0 || cfg.Argon2Parallelism > 255 {
+		return nil, fmt.Errorf("некорректные параметры argon2id")
+	}
+
+	bcryptHasher := &BcryptHasher{Cost: bcryptCost}
+	argon2Hasher := &Argon2idHasher{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}
+	if argon2Hasher.Memory == 0 {
+		argon2Hasher.Memory = 64 * 1024
+	}
+	if argon2Hasher.Iterations == 0 {
+		argon2Hasher.Iterations = 1
+	}
+	if argon2Hasher.Parallelism == 0 {
+		argon2Hasher.Parallelism = 4
+	}
+
+	passwords := &Passwords{hashers: []PasswordHasher{bcryptHasher, argon2Hasher}}
+
+	switch cfg.PasswordHash {
+	case "", PasswordHashBcrypt:
+		passwords.current = bcryptHasher
+	case PasswordHashArgon2id:
+		passwords.current = argon2Hasher
+	default:
+		return nil, fmt.Errorf("неизвестный алгоритм хеширования паролей: %q", cfg.PasswordHash)
+	}
+
+	return passwords, nil
+}
+
+func (p *Passwords) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+// Verify проверяет пароль и сообщает, нужно ли пересчитать хеш текущим алгоритмом с текущими параметрами.
+func (p *Passwords) Verify(password string, encoded string) (ok bool, needsRehash bool, err error) {
+
+	for _, hasher := range p.hashers {
+		if !hasher.Supports(encoded) {
+			continue
+		}
+
+		ok, err = hasher.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+
+		return true, hasher != p.current || hasher.NeedsRehash(encoded), nil
+	}
+
+	return false, false, fmt.Errorf("неизвестный формат хеша пароля")
+}
+
+// CheckPasswordPolicy проверяет, что пароль соответствует минимальным требованиям.
+func CheckPasswordPolicy(login string, password string, minLength int) error {
+
+	if utf8.RuneCountInString(password) < minLength {
+		return fmt.Errorf("пароль должен содержать не менее %d символов", minLength)
+	}
+
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("пароль не должен быть длиннее %d байт", maxPasswordLength)
+	}
+
+	if strings.EqualFold(password, login) {
+		return fmt.Errorf("пароль не должен совпадать с логином")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("пароль должен содержать буквы и цифры")
+	}
+
+	return nil
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func (h *BcryptHasher) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *BcryptHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher хранит хеш в формате PHC: $argon2id$v=19$m=65536,t=1,p=4$<соль>$<хеш>.
+type Argon2idHasher struct {
+	Memory      uint32 // КиБ
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(password string, encoded string) (bool, error) {
+
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (h *Argon2idHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.Memory || params.iterations != h.Iterations || params.parallelism != h.Parallelism ||
+		len(params.key) != argon2KeyLength
+}
+
+func decodeArgon2id(encoded string) (params argon2Params, err error) {
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, fmt.Errorf("некорректный формат хеша argon2id")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, fmt.Errorf("некорректная версия argon2id: %w", err)
+	}
+	if version != argon2.Version {
+		return params, fmt.Errorf("неподдерживаемая версия argon2id: %d", version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil {
+		return params, fmt.Errorf("некорректные параметры argon2id: %w", err)
+	}
+
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, fmt.Errorf("некорректная соль argon2id: %w", err)
+	}
+
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, fmt.Errorf("некорректный хеш argon2id: %w", err)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,174 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/utils"
+)
+
+// небольшие параметры, чтобы тесты не тратили время на хеширование
+var (
+	bcryptConfig   = config.Config{PasswordHash: utils.PasswordHashBcrypt, BcryptCost: 4}
+	argon2idConfig = config.Config{PasswordHash: utils.PasswordHashArgon2id, Argon2Memory: 1024, Argon2Iterations: 1, Argon2Parallelism: 1}
+)
+
+func newPasswords(t *testing.T, cfg config.Config) *utils.Passwords {
+	t.Helper()
+
+	passwords, err := utils.NewPasswords(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passwords
+}
+
+func TestPasswordsVerify(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		prefix string
+	}{
+		{name: utils.PasswordHashBcrypt, cfg: bcryptConfig, prefix: "$2a$04$"},
+		{name: utils.PasswordHashArgon2id, cfg: argon2idConfig, prefix: "$argon2id$v=19$m=1024,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passwords := newPasswords(t, tt.cfg)
+
+			encoded, err := passwords.Hash("secret123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(encoded, tt.prefix) {
+				t.Fatalf("хеш %q, ожидался префикс %q", encoded, tt.prefix)
+			}
+
+			again, err := passwords.Hash("secret123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again == encoded {
+				t.Error("хеши одного пароля совпали: соль не используется")
+			}
+
+			ok, needsRehash, err := passwords.Verify("secret123", encoded)
+			if err != nil || !ok || needsRehash {
+				t.Errorf("верный пароль: ok=%v, needsRehash=%v, err=%v", ok, needsRehash, err)
+			}
+
+			ok, needsRehash, err = passwords.Verify("secret124", encoded)
+			if err != nil || ok || needsRehash {
+				t.Errorf("неверный пароль: ok=%v, needsRehash=%v, err=%v", ok, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestPasswordsNeedsRehash(t *testing.T) {
+
+	withArgon2id := func(memory, iterations, parallelism int) config.Config {
+		cfg := argon2idConfig
+		cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism = memory, iterations, parallelism
+		return cfg
+	}
+
+	tests := []struct {
+		name        string
+		hashed      config.Config
+		current     config.Config
+		needsRehash bool
+	}{
+		{name: "bcrypt без изменений", hashed: bcryptConfig, current: bcryptConfig},
+		{name: "argon2id без изменений", hashed: argon2idConfig, current: argon2idConfig},
+		{name: "стоимость bcrypt", hashed: bcryptConfig, current: config.Config{PasswordHash: utils.PasswordHashBcrypt, BcryptCost: 5}, needsRehash: true},
+		{name: "память argon2id", hashed: argon2idConfig, current: withArgon2id(2048, 1, 1), needsRehash: true},
+		{name: "итерации argon2id", hashed: argon2idConfig, current: withArgon2id(1024, 2, 1), needsRehash: true},
+		{name: "параллелизм argon2id", hashed: argon2idConfig, current: withArgon2id(1024, 1, 2), needsRehash: true},
+		{name: "bcrypt на argon2id", hashed: bcryptConfig, current: argon2idConfig, needsRehash: true},
+		{name: "argon2id на bcrypt", hashed: argon2idConfig, current: bcryptConfig, needsRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := newPasswords(t, tt.hashed).Hash("secret123")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// хеш, полученный с прежними параметрами, по-прежнему проверяется
+			ok, needsRehash, err := newPasswords(t, tt.current).Verify("secret123", encoded)
+			if err != nil || !ok {
+				t.Fatalf("ok=%v, err=%v, ожидалась успешная проверка", ok, err)
+			}
+			if needsRehash != tt.needsRehash {
+				t.Errorf("needsRehash=%v, ожидалось %v", needsRehash, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestPasswordsVerifyMalformed(t *testing.T) {
+
+	passwords := newPasswords(t, bcryptConfig)
+
+	for _, encoded := range []string{
+		"secret123",
+		"$argon2id$v=19$m=1024,t=1,p=1$соль",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$!",
+	} {
+		if ok, _, err := passwords.Verify("secret123", encoded); err == nil || ok {
+			t.Errorf("хеш %q: ok=%v, err=%v, ожидалась ошибка", encoded, ok, err)
+		}
+	}
+}
+
+func TestNewPasswordsValidation(t *testing.T) {
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{name: "неизвестный алгоритм", cfg: config.Config{PasswordHash: "md5"}},
+		{name: "стоимость bcrypt", cfg: config.Config{BcryptCost: 32}},
+		{name: "параллелизм argon2id", cfg: config.Config{Argon2Parallelism: 256}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := utils.NewPasswords(&tt.cfg); err == nil {
+				t.Error("ожидалась ошибка конфигурации")
+			}
+		})
+	}
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{name: "допустимый", password: "secret123", valid: true},
+		{name: "длина в символах, а не в байтах", password: "пароль12", valid: true},
+		{name: "короткий", password: "secret1"},
+		{name: "длиннее 72 байт", password: strings.Repeat("пароль", 6) + "1"},
+		{name: "совпадает с логином", password: "Alice2024"},
+		{name: "без цифр", password: "secretsecret"},
+		{name: "без букв", password: "1234567890"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := utils.CheckPasswordPolicy("alice2024", tt.password, 8)
+			if (err == nil) != tt.valid {
+				t.Errorf("CheckPasswordPolicy(%q) = %v", tt.password, err)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package utils
 import (
 	"fmt"
 	"strconv"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func CheckOrderNumber(orderNumberS string) (orderNumberI int64, err error) {
@@ -61,11 +59,3 @@ func isValidLuhn(number string) bool {
 	// Если сумма кратна 10, то номер корректен
 	return sum%10 == 0
 }
-
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedPassword), nil
-}
@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/accrualservice"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/customerrors"
+	"github.com/maryakotova/gophermart/internal/metrics"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage"
+	"go.uber.org/zap"
+)
+
+// orderLease - время, на которое заказ резервируется за воркером. Заказ, не доведённый
+// до финального статуса, снова становится доступен в очереди по его истечении.
+const orderLease = 10 * time.Second
+
+// AccrualWorkerPool опрашивает систему расчёта начислений по заказам из очереди в Postgres.
+// Очередь хранится в базе, поэтому после перезапуска обработка продолжается.
+type AccrualWorkerPool struct {
+	config  *config.Config
+	logger  *zap.Logger
+	storage storage.Storage
+	accrual *accrualservice.AccrualService
+	lease   time.Duration
+
+	jobs       chan models.OrderToProcess
+	mtx        sync.Mutex
+	inProgress map[int64]struct{}
+}
+
+func NewAccrualWorkerPool(cfg *config.Config, logger *zap.Logger, storage storage.Storage, accrual *accrualservice.AccrualService) *AccrualWorkerPool {
+	return &AccrualWorkerPool{
+		config:     cfg,
+		logger:     logger,
+		storage:    storage,
+		accrual:    accrual,
+		lease:      orderLease,
+		jobs:       make(chan models.OrderToProcess),
+		inProgress: make(map[int64]struct{}),
+	}
+}
+
+// Run запускает воркеры и блокируется до отмены контекста. После отмены новые заказы
+// не загружаются, а Run возвращается, когда воркеры доведут обработку текущих заказов.
+func (p *AccrualWorkerPool) Run(ctx context.Context) {
+
+	workers := p.config.AccrualWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	interval := p.config.AccrualPollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// пока очередь выдаёт полные пачки, загружаем следующую без ожидания
+		if p.loadOrders(ctx, workers) == workers && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *AccrualWorkerPool) loadOrders(ctx context.Context, limit int) int {
+
+	orders, err := p.storage.DequeueOrders(ctx, limit, p.lease)
+	if err != nil {
+		p.logger.Error("ошибка при загрузке заказов из очереди", zap.Error(err))
+		return 0
+	}
+
+	for _, order := range orders {
+		if !p.lock(order.OrderNumber) {
+			continue
+		}
+
+		select {
+		case p.jobs <- order:
+		case <-ctx.Done():
+			p.unlock(order.OrderNumber)
+			return 0
+		}
+	}
+
+	return len(orders)
+}
+
+func (p *AccrualWorkerPool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case order := <-p.jobs:
+			p.process(ctx, order)
+			p.unlock(order.OrderNumber)
+		}
+	}
+}
+
+func (p *AccrualWorkerPool) process(ctx context.Context, order models.OrderToProcess) {
+
+	if err := p.accrual.WaitThrottle(ctx); err != nil {
+		return
+	}
+	if err := p.accrual.WaitAvailable(ctx); err != nil {
+		return
+	}
+
+	// начатую обработку доводим до конца и при остановке сервиса, чтобы не терять полученный ответ;
+	// время на неё ограничено таймаутом остановки приложения
+	ctx = context.WithoutCancel(ctx)
+
+	response, err := p.accrual.GetAccrualFromService(ctx, order.OrderNumber)
+	if errors.Is(err, customerrors.ErrAccrualThrottled) || errors.Is(err, customerrors.ErrAccrualUnavailable) {
+		// заказ остаётся в очереди и будет загружен повторно после снятия ограничения или восстановления системы расчёта;
+		// о недоступности системы расчёта сообщает предохранитель при размыкании
+		p.logger.Debug("заказ отложен", zap.Int64("order", order.OrderNumber), zap.Error(err))
+		return
+	}
+	if err != nil {
+		p.logger.Error("ошибка при запросе к системе расчёта начислений", zap.Int64("order", order.OrderNumber), zap.Error(err))
+		return
+	}
+
+	if response.Status == order.Status && response.Accrual == order.Accrual {
+		return
+	}
+
+	err = p.storage.UpdateOrder(ctx, response)
+	if err != nil {
+		p.logger.Error("ошибка при обновлении заказа", zap.Int64("order", order.OrderNumber), zap.Error(err))
+		return
+	}
+
+	if isFinalStatus(response.Status) {
+		metrics.OrdersProcessed.Inc(response.Status)
+		if response.Status == constants.Processed {
+			metrics.PointsAccrued.Add(response.Accrual.Float64())
+		}
+	}
+
+	p.logger.Info("статус заказа обновлён",
+		zap.Int64("order", order.OrderNumber),
+		zap.String("status", response.Status),
+		zap.Stringer("accrual", response.Accrual),
+	)
+}
+
+func isFinalStatus(status string) bool {
+	return status == constants.Processed || status == constants.Invalid || status == constants.NotRelevant
+}
+
+func (p *AccrualWorkerPool) lock(orderNumber int64) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if _, ok := p.inProgress[orderNumber]; ok {
+		return false
+	}
+	p.inProgress[orderNumber] = struct{}{}
+	return true
+}
+
+func (p *AccrualWorkerPool) unlock(orderNumber int64) {
+	p.mtx.Lock()
+	delete(p.inProgress, orderNumber)
+	p.mtx.Unlock()
+}
@@ -0,0 +1,270 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/accrualservice"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/constants"
+	"github.com/maryakotova/gophermart/internal/fakeaccrual"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage/memory"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	testOrder  = 12345678903
+	testLease  = 20 * time.Millisecond
+	waitFinish = 5 * time.Second
+)
+
+type testEnv struct {
+	store  *memory.MemoryStorage
+	fake   *fakeaccrual.Server
+	cfg    *config.Config
+	userID int
+
+	errors atomic.Int64 // записи в журнал с уровнем error и выше
+	logger *zap.Logger
+}
+
+// newTestEnv создаёт хранилище в памяти с одним заказом в очереди и имитацию системы расчёта со сценарием fakeCfg.
+func newTestEnv(t *testing.T, fakeCfg fakeaccrual.Config) *testEnv {
+	t.Helper()
+
+	srv, fake, err := fakeaccrual.NewTestServer(fakeCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	env := &testEnv{
+		fake: fake,
+		cfg: &config.Config{
+			AccrualSystemAddress: fakeaccrual.Address(srv),
+			AccrualTimeout:       time.Second,
+			AccrualBreakerFails:  2,
+			AccrualBreakerWait:   time.Minute,
+			AccrualWorkers:       2,
+			AccrualPollInterval:  5 * time.Millisecond,
+		},
+	}
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zap.DebugLevel)
+	env.logger = zap.New(core, zap.Hooks(func(entry zapcore.Entry) error {
+		if entry.Level >= zap.ErrorLevel {
+			env.errors.Add(1)
+		}
+		return nil
+	}))
+
+	env.store = memory.NewMemoryStorage(env.cfg, env.logger)
+
+	ctx := context.Background()
+	env.userID, err = env.store.CreateUser(ctx, "user", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.InsertOrder(ctx, env.userID, testOrder); err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func (env *testEnv) newPool(t *testing.T) *AccrualWorkerPool {
+	t.Helper()
+
+	accrual, err := accrualservice.NewAccrualSystem(env.cfg, env.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewAccrualWorkerPool(env.cfg, env.logger, env.store, accrual)
+	pool.lease = testLease
+	return pool
+}
+
+// run запускает пулы воркеров и останавливает их, когда выполнится условие done или истечёт timeout.
+func run(t *testing.T, timeout time.Duration, done func() bool, pools ...*AccrualWorkerPool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool *AccrualWorkerPool) {
+			defer wg.Done()
+			pool.Run(ctx)
+		}(pool)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !done() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+func (env *testEnv) queueEmpty(t *testing.T) func() bool {
+	return func() bool {
+		count, err := env.store.CountQueuedOrders(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count == 0
+	}
+}
+
+func (env *testEnv) expectOrder(t *testing.T, status string, balance models.Points) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	orders, err := env.store.GetOrdersForUser(ctx, env.userID, models.ListQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Status != status {
+		t.Fatalf("заказы: %+v, ожидался один заказ в статусе %s", orders, status)
+	}
+
+	current, err := env.store.GetCurrentBalance(ctx, env.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, err := env.store.GetLedgerBalance(ctx, env.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != balance || ledger != balance {
+		t.Fatalf("остаток %s, по журналу %s, ожидалось %s", current, ledger, balance)
+	}
+}
+
+func TestProcessedCreditedOnce(t *testing.T) {
+
+	env := newTestEnv(t, fakeaccrual.Config{
+		Orders: map[string]fakeaccrual.Script{
+			fmt.Sprint(testOrder): {Statuses: []string{constants.Registered, constants.Processing, constants.Processed}, Accrual: models.Points(50000)},
+		},
+	})
+
+	// два экземпляра сервиса обрабатывают одну очередь; аренда короткая, поэтому заказ может попасть к обоим
+	run(t, waitFinish, env.queueEmpty(t), env.newPool(t), env.newPool(t))
+
+	if !env.queueEmpty(t)() {
+		t.Fatal("заказ не обработан")
+	}
+	env.expectOrder(t, constants.Processed, models.Points(50000))
+
+	// повторный ответ PROCESSED по уже обработанному заказу не начисляет баллы ещё раз
+	pool := env.newPool(t)
+	pool.process(context.Background(), models.OrderToProcess{OrderNumber: testOrder, Status: constants.Processing})
+	env.expectOrder(t, constants.Processed, models.Points(50000))
+
+	if env.errors.Load() != 0 {
+		t.Errorf("в журнал записано %d ошибок", env.errors.Load())
+	}
+}
+
+func TestFinalStatusRemovesFromQueue(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		fakeCfg fakeaccrual.Config
+		status  string
+	}{
+		{
+			name: constants.Invalid,
+			fakeCfg: fakeaccrual.Config{Orders: map[string]fakeaccrual.Script{
+				fmt.Sprint(testOrder): {Statuses: []string{constants.Processing, constants.Invalid}},
+			}},
+			status: constants.Invalid,
+		},
+		{
+			name:    constants.NotRelevant,
+			fakeCfg: fakeaccrual.Config{UnknownNoContent: true},
+			status:  constants.NotRelevant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, tt.fakeCfg)
+
+			run(t, waitFinish, env.queueEmpty(t), env.newPool(t))
+
+			if !env.queueEmpty(t)() {
+				t.Fatal("заказ в финальном статусе остался в очереди")
+			}
+			env.expectOrder(t, tt.status, 0)
+		})
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+
+	env := newTestEnv(t, fakeaccrual.Config{
+		Orders: map[string]fakeaccrual.Script{
+			fmt.Sprint(testOrder): {Statuses: []string{constants.Processed}, Accrual: models.Points(100)},
+		},
+	})
+	ctx := context.Background()
+
+	// заказ арендован воркером, который так и не вернул результат
+	orders, err := env.store.DequeueOrders(ctx, 10, testLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("из очереди получено %d заказов, ожидался 1", len(orders))
+	}
+
+	orders, err = env.store.DequeueOrders(ctx, 10, testLease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Fatal("арендованный заказ выдан повторно до истечения аренды")
+	}
+
+	time.Sleep(testLease)
+
+	// по истечении аренды заказ снова доступен и обрабатывается другим воркером
+	run(t, waitFinish, env.queueEmpty(t), env.newPool(t))
+
+	if !env.queueEmpty(t)() {
+		t.Fatal("заказ не обработан после истечения аренды")
+	}
+	env.expectOrder(t, constants.Processed, models.Points(100))
+}
+
+func TestAccrualUnavailable(t *testing.T) {
+
+	env := newTestEnv(t, fakeaccrual.Config{ErrorRate: 1})
+
+	run(t, 200*time.Millisecond, func() bool { return false }, env.newPool(t))
+
+	// после AccrualBreakerFails неудач предохранитель размыкается, и воркеры ждут пробного запроса
+	if requests := env.fake.Requests(fmt.Sprint(testOrder)); requests != env.cfg.AccrualBreakerFails {
+		t.Errorf("система расчёта получила %d запросов, ожидалось %d", requests, env.cfg.AccrualBreakerFails)
+	}
+	if env.queueEmpty(t)() {
+		t.Error("заказ удалён из очереди, хотя система расчёта недоступна")
+	}
+	if env.errors.Load() != 0 {
+		t.Errorf("недоступность системы расчёта записана в журнал как %d ошибок", env.errors.Load())
+	}
+	env.expectOrder(t, constants.New, 0)
+}
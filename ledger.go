@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/models"
+	"github.com/maryakotova/gophermart/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+// runLedger выполняет подкоманду ledger для финансовой службы:
+// entries <логин> - операции по счёту пользователя с нарастающим остатком,
+// reconcile [логин] - сверка остатка с журналом (без логина - по всем пользователям),
+// adjust <логин> <сумма> <комментарий> - ручная корректировка баланса.
+func runLedger(cfg *config.Config, log *zap.Logger, args []string) error {
+
+	if cfg.DatabaseURI == "" {
+		return fmt.Errorf("адрес базы данных не задан")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("использование: ledger entries <логин> | reconcile [логин] | adjust <логин> <сумма> <комментарий>")
+	}
+
+	storage, err := postgres.NewPostgresStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	userID := func(login string) (int, error) {
+		id, err := storage.GetUserID(ctx, login)
+		if err != nil {
+			return 0, err
+		}
+		if id <= 0 {
+			return 0, fmt.Errorf("пользователь %q не найден", login)
+		}
+		return id, nil
+	}
+
+	switch args[0] {
+	case "entries":
+		if len(args) < 2 {
+			return fmt.Errorf("не указан логин")
+		}
+		id, err := userID(args[1])
+		if err != nil {
+			return err
+		}
+		entries, err := storage.GetLedgerEntries(ctx, id)
+		if err != nil {
+			return err
+		}
+		var balance models.Points
+		for _, entry := range entries {
+			balance += entry.Amount
+			order := "-"
+			if entry.OrderNumber != 0 {
+				order = fmt.Sprint(entry.OrderNumber)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\tостаток: %s\t%s\n", entry.CreatedAt.Format(time.DateTime), entry.Kind, order,
+				entry.Amount, balance, entry.Comment)
+		}
+		return nil
+
+	case "reconcile":
+		if len(args) < 2 {
+			mismatches, err := storage.GetBalanceMismatches(ctx)
+			if err != nil {
+				return err
+			}
+			for _, mismatch := range mismatches {
+				fmt.Printf("%s\tостаток: %s\tпо журналу: %s\tрасхождение: %s\n", mismatch.Login, mismatch.Balance,
+					mismatch.LedgerBalance, mismatch.Balance-mismatch.LedgerBalance)
+			}
+			if len(mismatches) == 0 {
+				fmt.Println("расхождений нет")
+			}
+			return nil
+		}
+		id, err := userID(args[1])
+		if err != nil {
+			return err
+		}
+		balance, err := storage.GetCurrentBalance(ctx, id)
+		if err != nil {
+			return err
+		}
+		ledgerBalance, err := storage.GetLedgerBalance(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("остаток: %s\tпо журналу: %s\tрасхождение: %s\n", balance, ledgerBalance, balance-ledgerBalance)
+		return nil
+
+	case "adjust":
+		if len(args) < 4 {
+			return fmt.Errorf("нужно указать логин, сумму и комментарий")
+		}
+		id, err := userID(args[1])
+		if err != nil {
+			return err
+		}
+		points, err := models.ParsePoints(args[2])
+		if err != nil {
+			return err
+		}
+		if points == 0 {
+			return fmt.Errorf("сумма корректировки не может быть нулевой")
+		}
+		comment := strings.Join(args[3:], " ")
+		if err := storage.AdjustBalance(ctx, id, points, comment); err != nil {
+			return err
+		}
+		log.Info("баланс скорректирован", zap.String("login", args[1]), zap.Stringer("sum", points), zap.String("comment", comment))
+		return nil
+
+	default:
+		return fmt.Errorf("неизвестная команда ledger: %s", args[0])
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/clock"
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/loginguard"
+	"github.com/maryakotova/gophermart/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+// runLockouts выполняет подкоманду lockouts для службы поддержки:
+// list [логин] - последние блокировки входа, unlock <логин> и unlock-ip <адрес> - снятие блокировки.
+func runLockouts(cfg *config.Config, log *zap.Logger, args []string) error {
+
+	if cfg.DatabaseURI == "" {
+		return fmt.Errorf("адрес базы данных не задан")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("использование: lockouts list [логин] | unlock <логин> | unlock-ip <адрес>")
+	}
+
+	storage, err := postgres.NewPostgresStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	guard := loginguard.NewGuard(cfg, log, storage, clock.Real{})
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		login := ""
+		if len(args) > 1 {
+			login = args[1]
+		}
+		lockouts, err := guard.Lockouts(ctx, login, 50)
+		if err != nil {
+			return err
+		}
+		for _, lockout := range lockouts {
+			state := "до " + lockout.LockedUntil.Format(time.DateTime)
+			if !lockout.UnlockedAt.IsZero() {
+				state = "снята " + lockout.UnlockedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%s\t%s\tпопыток: %d\t%s\n", lockout.LockedAt.Format(time.DateTime), lockout.Key, lockout.Failures, state)
+		}
+		return nil
+
+	case "unlock":
+		if len(args) < 2 {
+			return fmt.Errorf("не указан логин")
+		}
+		return guard.Unlock(ctx, args[1])
+
+	case "unlock-ip":
+		if len(args) < 2 {
+			return fmt.Errorf("не указан IP-адрес")
+		}
+		return guard.UnlockIP(ctx, args[1])
+
+	default:
+		return fmt.Errorf("неизвестная команда lockouts: %s", args[0])
+	}
+}
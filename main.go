@@ -1,15 +1,26 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"flag"
 
 	"github.com/go-chi/chi"
 	"github.com/maryakotova/gophermart/internal/accrualservice"
+	"github.com/maryakotova/gophermart/internal/app"
+	"github.com/maryakotova/gophermart/internal/authutils"
+	"github.com/maryakotova/gophermart/internal/clock"
 	"github.com/maryakotova/gophermart/internal/config"
 	"github.com/maryakotova/gophermart/internal/handlers"
+	"github.com/maryakotova/gophermart/internal/health"
+	"github.com/maryakotova/gophermart/internal/idempotency"
 	"github.com/maryakotova/gophermart/internal/logger"
+	"github.com/maryakotova/gophermart/internal/loginguard"
+	"github.com/maryakotova/gophermart/internal/metrics"
+	"github.com/maryakotova/gophermart/internal/ratelimit"
 	"github.com/maryakotova/gophermart/internal/service"
 	"github.com/maryakotova/gophermart/internal/storage"
+	"github.com/maryakotova/gophermart/internal/utils"
+	"github.com/maryakotova/gophermart/internal/worker"
 )
 
 func main() {
@@ -19,38 +30,131 @@ func main() {
 		panic(err)
 	}
 
-	config := config.NewConfig()
+	config, err := config.NewConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(config, log, args[1:])
+		case "lockouts":
+			err = runLockouts(config, log, args[1:])
+		case "ledger":
+			err = runLedger(config, log, args[1:])
+		case "order-stats":
+			err = runOrderStats(config, log, args[1:])
+		case "fake-accrual":
+			err = runFakeAccrual(config, log, args[1:])
+		default:
+			log.Fatal("неизвестная команда: " + args[0])
+		}
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 
 	factory := &storage.StorageFactory{}
 
-	storage, err := factory.NewStorage(config, log)
+	db, err := factory.NewStorage(config, log)
 	if err != nil {
 		panic(err)
 	}
+	storage := storage.NewInstrumentedStorage(db)
 
 	accrual, err := accrualservice.NewAccrualSystem(config, log)
 	if err != nil {
 		panic(err)
 	}
 
-	service := service.NewService(&storage, log, accrual)
+	passwords, err := utils.NewPasswords(config)
+	if err != nil {
+		panic(err)
+	}
+
+	service := service.NewService(&storage, log, passwords)
 
-	handler := handlers.NewHandler(config, log, service)
+	accrualWorkers := worker.NewAccrualWorkerPool(config, log, storage, accrual)
 
-	router := chi.NewRouter()
-	router.Use()
+	tokens, err := authutils.NewTokenManager(config, log, storage)
+	if err != nil {
+		panic(err)
+	}
+
+	guard := loginguard.NewGuard(config, log, storage, clock.Real{})
 
-	router.Post("/api/user/register", logger.WithLogging(handler.Register))
-	router.Post("/api/user/login", logger.WithLogging(handler.Login))
-	router.Post("/api/user/orders", logger.WithLogging(handler.LoadOrder))
-	router.Get("/api/user/orders", logger.WithLogging(handler.GetOrderList))
-	router.Get("/api/user/balance", logger.WithLogging(handler.GetBalance))
-	router.Post("/api/user/balance/withdraw", logger.WithLogging(handler.Withdraw))
-	router.Get("/api/user/withdrawals", logger.WithLogging(handler.GetWithdraws))
+	handler := handlers.NewHandler(config, log, service, tokens, guard)
 
-	err = http.ListenAndServe(config.RunAddress, router)
+	var rateStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.RateLimitStore == ratelimit.StorePostgres {
+		var ok bool
+		rateStore, ok = db.(ratelimit.Store)
+		if !ok {
+			log.Fatal("хранилище счётчиков postgres требует подключения к базе данных")
+		}
+	}
+
+	limiter, err := ratelimit.NewLimiter(config, log, rateStore, clock.Real{})
 	if err != nil {
 		panic(err)
 	}
 
+	idempotencyKeys := idempotency.NewKeeper(config, log, storage, clock.Real{})
+
+	healthHandler := handlers.NewHealthHandler(config, log, health.NewChecker(config, log, storage, accrual))
+
+	metrics.Default.NewGaugeFunc("gophermart_accrual_circuit_state", "Состояние предохранителя запросов к системе расчёта: 0 - замкнут, 1 - пробный запрос, 2 - разомкнут.",
+		func(ctx context.Context) (float64, error) {
+			return float64(accrual.BreakerState().State), nil
+		})
+	metrics.Default.NewGaugeFunc("gophermart_accrual_queue_depth", "Количество заказов, ожидающих расчёта начислений.",
+		func(ctx context.Context) (float64, error) {
+			count, err := storage.CountQueuedOrders(ctx)
+			return float64(count), err
+		})
+
+	router := chi.NewRouter()
+	router.Use(metrics.Middleware)
+	router.Use(logger.Middleware)
+
+	router.Handle("/metrics", metrics.Default.Handler())
+
+	// проверки состояния для оркестратора
+	router.Get("/healthz", healthHandler.Liveness)
+	router.Get("/readyz", healthHandler.Readiness)
+	router.Get("/status", healthHandler.Status)
+
+	// публичные маршруты
+	router.Group(func(r chi.Router) {
+		r.With(limiter.Middleware("register")).Post("/api/user/register", handler.Register)
+		r.With(limiter.Middleware("login")).Post("/api/user/login", handler.Login)
+		r.With(limiter.Middleware("refresh")).Post("/api/user/token/refresh", handler.RefreshToken)
+		r.Get("/.well-known/jwks.json", handler.GetJWKS)
+	})
+
+	// маршруты, доступные только аутентифицированным пользователям
+	router.Group(func(r chi.Router) {
+		r.Use(tokens.Middleware)
+		r.Use(limiter.Middleware("default"))
+		r.With(limiter.Middleware("orders"), idempotencyKeys.Middleware).Post("/api/user/orders", handler.LoadOrder)
+		r.With(limiter.Middleware("orders"), idempotencyKeys.Middleware).Post("/api/user/orders/batch", handler.LoadOrderBatch)
+		r.Get("/api/user/orders", handler.GetOrderList)
+		r.Get("/api/user/orders/stats", handler.GetOrderStats)
+		r.Get("/api/user/orders/{number}", handler.GetOrder)
+		r.Get("/api/user/orders/{number}/timeline", handler.GetOrderTimeline)
+		r.Get("/api/user/balance", handler.GetBalance)
+		r.With(limiter.Middleware("withdraw"), idempotencyKeys.Middleware).Post("/api/user/balance/withdraw", handler.Withdraw)
+		r.Get("/api/user/withdrawals", handler.GetWithdraws)
+		r.Post("/api/user/logout", handler.Logout)
+		r.Post("/api/user/logout/all", handler.LogoutAll)
+		r.Get("/api/user/sessions", handler.GetSessions)
+	})
+
+	application := app.NewApp(config, log, storage, router, accrualWorkers)
+	if err := application.Run(context.Background()); err != nil {
+		log.Fatal(err.Error())
+	}
+
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+// runMigrate выполняет подкоманду migrate: up, down [количество шагов] или status.
+func runMigrate(cfg *config.Config, log *zap.Logger, args []string) error {
+
+	if cfg.DatabaseURI == "" {
+		return fmt.Errorf("адрес базы данных не задан")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("использование: migrate up | down [n] | status")
+	}
+
+	storage, err := postgres.NewPostgresStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return storage.MigrateUp(ctx)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("некорректное количество шагов отката: %s", args[1])
+			}
+		}
+		return storage.MigrateDown(ctx, steps)
+
+	case "status":
+		statuses, err := storage.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "не применена"
+			if status.Applied {
+				state = "применена " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("неизвестная команда migrate: %s", args[0])
+	}
+}
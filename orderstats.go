@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/maryakotova/gophermart/internal/config"
+	"github.com/maryakotova/gophermart/internal/storage/postgres"
+	"go.uber.org/zap"
+)
+
+// runOrderStats выполняет подкоманду order-stats [дней]: выводит по дням статистику времени
+// от загрузки заказа до финального статуса по всем пользователям, по умолчанию за последние 7 дней.
+func runOrderStats(cfg *config.Config, log *zap.Logger, args []string) error {
+
+	if cfg.DatabaseURI == "" {
+		return fmt.Errorf("адрес базы данных не задан")
+	}
+
+	days := 7
+	if len(args) > 0 {
+		var err error
+		days, err = strconv.Atoi(args[0])
+		if err != nil || days < 1 {
+			return fmt.Errorf("количество дней должно быть положительным числом")
+		}
+	}
+
+	storage, err := postgres.NewPostgresStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	to := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	from := to.AddDate(0, 0, -days)
+
+	stats, err := storage.GetOrderLatencyStats(context.Background(), 0, from, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("день\tзаказов\tсреднее\tмедиана\tp95\tмаксимум")
+	for _, day := range stats {
+		fmt.Printf("%s\t%d\t%s\t%s\t%s\t%s\n", day.Day.Format(time.DateOnly), day.Orders,
+			day.Avg.Round(time.Second), day.P50.Round(time.Second), day.P95.Round(time.Second), day.Max.Round(time.Second))
+	}
+
+	return nil
+}